@@ -3,6 +3,8 @@ package config
 import (
 	"flag"
 	"strings"
+
+	"github.com/itzloop/misura/wrapper/types"
 )
 
 type Measures []string
@@ -12,34 +14,45 @@ func (m *Measures) String() string {
 }
 
 func (i *Measures) Set(v string) error {
-	if v == "all" {
-		*i = []string{"all"}
-		return nil
+	for _, m := range strings.Split(v, ",") {
+		if m == "all" {
+			// all replaces other measures except opt-in ones
+			measures := []string{"all"}
+			for _, it := range *i {
+				if optInMeasure(it) {
+					measures = append(measures, it)
+				}
+			}
+
+			*i = measures
+			continue
+		}
+
+		if i.has(m) || (i.has("all") && !optInMeasure(m)) {
+			// don't add dups or measures included in all
+			continue
+		}
+
+		*i = append(*i, m)
 	}
 
+	return nil
+}
+
+func (i *Measures) has(v string) bool {
 	for _, it := range *i {
-		// if has all option don't add anything else
-		if it == "all" {
-			*i = []string{"all"}
-			return nil
-		}
 		if it == v {
-			// don't add dups
-			return nil
+			return true
 		}
 	}
 
-	if strings.Contains(v, ",") {
-		if strings.Contains(v, "all") {
-			*i = []string{"all"}
-			return nil
-		}
-		*i = append(*i, strings.Split(v, ",")...)
-		return nil
-	}
+	return false
+}
 
-	*i = append(*i, v)
-	return nil
+// optInMeasure reports whether v is a measure that is not included in all
+// since it adds methods to metrics, which would break existing backends.
+func optInMeasure(v string) bool {
+	return v == "panic" || v == "inflight" || v == "miss"
 }
 
 type Types []string
@@ -58,11 +71,40 @@ func (t *Types) Set(v string) error {
 	return nil
 }
 
+type Wrappers []string
+
+func (w *Wrappers) String() string {
+	return "[" + strings.Join(*w, ", ") + "]"
+}
+
+func (w *Wrappers) Set(v string) error {
+	for _, it := range strings.Split(v, ",") {
+		if types.Strings(*w).Exists(it) {
+			// don't add dups
+			continue
+		}
+
+		*w = append(*w, it)
+	}
+
+	return nil
+}
+
 type Config struct {
 	FormatImports *bool
 	ShowVersion   *bool
+	Capture       *bool
+	Recover       *bool
+	Classify      *bool
+	Sample        *bool
+	CtorError     *bool
+	Bench         *bool
+	Contract      *bool
+	SkipErrorless *bool
+	Options       *bool
 	Types         *Types
 	Measures      *Measures
+	Wrappers      *Wrappers
 	FilePath      *string
 
 	flagSet *flag.FlagSet
@@ -72,8 +114,18 @@ func NewConfig(name string) *Config {
 	cfg := &Config{
 		FormatImports: new(bool),
 		ShowVersion:   new(bool),
+		Capture:       new(bool),
+		Recover:       new(bool),
+		Classify:      new(bool),
+		Sample:        new(bool),
+		CtorError:     new(bool),
+		Bench:         new(bool),
+		Contract:      new(bool),
+		SkipErrorless: new(bool),
+		Options:       new(bool),
 		Types:         new(Types),
 		Measures:      new(Measures),
+		Wrappers:      new(Wrappers),
 		FilePath:      new(string),
 		// TODO: does this need to be more configurable?
 		flagSet: flag.NewFlagSet(name, flag.ExitOnError),
@@ -85,12 +137,36 @@ Can be repeated like '-t MyInterface -t MyStruct'
 Can also be comma seprated like '-t MyInterface,MyStruct'`)
 
 	cfg.flagSet.Var(cfg.Measures, "m", `Measures to include. 
-Possible values [all, duration, total, success, error].
+Possible values [all, duration, total, success, error, panic, inflight, miss].
 Can be reapeted like '-m duration -m total'
 Can also be comma seprated '-m duration,total'
-If 'all' is specified others will be ignored`)
+'all' includes duration, total, success and error, others are ignored except
+panic, inflight and miss which must be passed explicitly like '-m all,panic'`)
+
+	cfg.flagSet.Var(cfg.Wrappers, "w", `Wrappers to generate. Defaults to metrics.
+Possible values [metrics, retry, breaker, timeout, limit, interceptor, hooks, fake, record, fault, cache, hedge].
+Can be reapeted like '-w metrics -w retry'
+Can also be comma seprated '-w metrics,retry'`)
 
 	cfg.flagSet.BoolVar(cfg.FormatImports, "fmt", true, "If set to true, will run imports.Process on the generated wrapper")
+	cfg.flagSet.BoolVar(cfg.Capture, "capture", false, `If set to true, parameters and results of each call will be passed to metrics.
+Use //misura:redact=<name|type> on methods or types to hide sensitive values`)
+	cfg.flagSet.BoolVar(cfg.Recover, "recover", false, `If set to true, panics will be returned as *misura.PanicError
+for methods whose last result is an error. Other methods will re-panic`)
+	cfg.flagSet.BoolVar(cfg.Classify, "classify", false, `If set to true, errors will be classified using misura.ErrorClassifier
+and the class will be passed to Failure`)
+	cfg.flagSet.BoolVar(cfg.Sample, "sample", false, `If set to true, calls will be recorded only if misura.Sampler
+passed to the constructor samples them`)
+	cfg.flagSet.BoolVar(cfg.CtorError, "ctor-error", false, `If set to true, constructors of generated wrappers will return an error
+instead of panicking when wrapped is nil`)
+	cfg.flagSet.BoolVar(cfg.Bench, "bench", false, `If set to true, benchmarks comparing each method with and without
+the metrics wrapper will be generated in <file>_misura_bench_test.go`)
+	cfg.flagSet.BoolVar(cfg.Contract, "contract", false, `If set to true, tests checking the metrics wrapper forwards parameters and
+results unchanged and calls metrics as configured will be generated in <file>_misura_contract_test.go`)
+	cfg.flagSet.BoolVar(cfg.SkipErrorless, "skip-errorless", false, `If set to true, methods without an error result will not be timed
+and Success will not be called for them`)
+	cfg.flagSet.BoolVar(cfg.Options, "options", false, `If set to true, the constructor of the metrics wrapper will take wrapped and
+misura.Option values, i.e. misura.WithName or the generated With<Type>Recorder, instead of positional arguments`)
 	cfg.flagSet.BoolVar(cfg.ShowVersion, "version", false, "Show program version")
 	cfg.flagSet.BoolVar(cfg.ShowVersion, "v", false, "Show program version")
 	cfg.flagSet.StringVar(cfg.FilePath, "f", "", "File path to parse. Can be overwritten with GOFILE")
@@ -5,19 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
+	"log"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/itzloop/misura/misura"
 )
 
 // TODO find a better way to call with magic comment
 // TODO either by putting a binary in PATH or sth else
 //
-//go:generate misura
+//go:generate misura -m all,panic,inflight -w metrics,fault
 
 //misura:IPUtil
 type IPUtil interface {
@@ -29,10 +31,6 @@ type IPUtilImpl struct {
 }
 
 func (u *IPUtilImpl) PublicIP() (net.IP, error) {
-	if rand.Int()%10 == 0 {
-		return nil, errors.New("intentional error")
-	}
-
 	resp, err := http.Get("https://api.ipify.org/")
 	if err != nil {
 		return nil, err
@@ -49,10 +47,6 @@ func (u *IPUtilImpl) PublicIP() (net.IP, error) {
 }
 
 func (u *IPUtilImpl) LocalIPs() ([]net.IP, error) {
-	if rand.Int()%10 == 0 {
-		return nil, errors.New("intentional error")
-	}
-
 	ifaces, err := net.Interfaces()
 	if err != nil {
 		return nil, err
@@ -82,6 +76,8 @@ func (u *IPUtilImpl) LocalIPs() ([]net.IP, error) {
 }
 
 type Metrics struct {
+	*misura.InflightGauge
+
 	total      *atomic.Int64
 	errCnt     *atomic.Int64
 	successCnt *atomic.Int64
@@ -103,6 +99,16 @@ func (m *Metrics) Failure(_ context.Context, name, pkg, intr, method string, d t
 	m.errs = append(m.errs, err)
 	m.durations = append(m.durations, d)
 }
+func (m *Metrics) Panic(_ context.Context, name, pkg, intr, method string, d time.Duration, recovered any) {
+	fmt.Println("Panic", pkg, intr, method, recovered)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.durations = append(m.durations, d)
+}
+func (m *Metrics) Injected(_ context.Context, name, pkg, intr, method string, fault misura.Fault) {
+	fmt.Println("Injected", pkg, intr, method, fault.Error)
+}
 func (m *Metrics) Success(_ context.Context, name, pkg, intr, method string, d time.Duration) {
 	fmt.Println("Success", pkg, intr, method)
 	m.successCnt.Add(1)
@@ -156,15 +162,22 @@ func (m *Metrics) errors() string {
 func main() {
 
 	m := &Metrics{
-		total:      &atomic.Int64{},
-		errCnt:     &atomic.Int64{},
-		successCnt: &atomic.Int64{},
-		durations:  []time.Duration{},
-		errs:       []error{},
-		mu:         &sync.Mutex{},
+		InflightGauge: misura.NewInflightGauge(),
+		total:         &atomic.Int64{},
+		errCnt:        &atomic.Int64{},
+		successCnt:    &atomic.Int64{},
+		durations:     []time.Duration{},
+		errs:          []error{},
+		mu:            &sync.Mutex{},
+	}
+
+	// fail 10% of calls, can be changed using MISURA_FAULTS environment variable
+	injector := misura.NewFaultInjector(misura.Fault{Probability: 0.1, Error: "intentional error"})
+	if err := injector.LoadEnv(); err != nil {
+		log.Fatalln(err)
 	}
 
-	uPromWrapGen := NewIPUtilPrometheusWrapperImpl("iputil", &IPUtilImpl{}, m)
+	uPromWrapGen := NewIPUtilPrometheusWrapperImpl("iputil", NewIPUtilFaultWrapperImpl("iputil", &IPUtilImpl{}, injector, m), m, nil)
 
 	for i := 0; i < 100; i++ {
 		uPromWrapGen.PublicIP()
@@ -11,6 +11,8 @@ import (
 	"net"
 	"strings"
 	"time"
+
+	"github.com/itzloop/misura/misura"
 )
 
 // IPUtilPrometheusWrapperImpl wraps IPUtil and adds metrics like:
@@ -23,16 +25,52 @@ type IPUtilPrometheusWrapperImpl struct {
 	name    string
 	intr    string
 	wrapped IPUtil
+	clock   interface{ Now() time.Time }
 	metrics interface {
 		// Failure will be called when err != nil passing the duration and err to it
 		Failure(ctx context.Context, name, pkg, intr, method string, duration time.Duration, err error)
 		// Success will be called if err == nil passing the duration
 		Success(ctx context.Context, name, pkg, intr, method string, duration time.Duration)
+		// Panic will be called when the wrapped method panics passing the duration and recovered value to it
+		Panic(ctx context.Context, name, pkg, intr, method string, duration time.Duration, recovered any)
+		// Inflight will be called with delta = 1 when the function is called and delta = -1 when it returns.
+		Inflight(ctx context.Context, name, pkg, intr, method string, delta int)
 		// Total will be called as soon as the function is called.
 		Total(ctx context.Context, name, pkg, intr, method string)
 	}
 }
 
+var _ IPUtil = (*IPUtilPrometheusWrapperImpl)(nil)
+
+// noopIPUtilMetrics is used by NewIPUtilPrometheusWrapperImpl
+// when metrics is nil and does nothing.
+type noopIPUtilMetrics struct{}
+
+func (noopIPUtilMetrics) Failure(ctx context.Context, name, pkg, intr, method string, duration time.Duration, err error) {
+}
+
+func (noopIPUtilMetrics) Success(ctx context.Context, name, pkg, intr, method string, duration time.Duration) {
+}
+
+func (noopIPUtilMetrics) Panic(ctx context.Context, name, pkg, intr, method string, duration time.Duration, recovered any) {
+}
+
+func (noopIPUtilMetrics) Inflight(ctx context.Context, name, pkg, intr, method string, delta int) {
+}
+
+func (noopIPUtilMetrics) Total(ctx context.Context, name, pkg, intr, method string) {
+}
+
+// systemIPUtilClock is used by NewIPUtilPrometheusWrapperImpl
+// when clock is nil.
+type systemIPUtilClock struct{}
+
+func (systemIPUtilClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewIPUtilPrometheusWrapperImpl creates a IPUtilPrometheusWrapperImpl. If metrics is nil, calls are
+// not recorded. It panics if wrapped is nil.
 func NewIPUtilPrometheusWrapperImpl(
 	name string,
 	wrapped IPUtil,
@@ -41,10 +79,28 @@ func NewIPUtilPrometheusWrapperImpl(
 		Failure(ctx context.Context, name, pkg, intr, method string, duration time.Duration, err error)
 		// Success will be called if err == nil passing the duration
 		Success(ctx context.Context, name, pkg, intr, method string, duration time.Duration)
+		// Panic will be called when the wrapped method panics passing the duration and recovered value to it
+		Panic(ctx context.Context, name, pkg, intr, method string, duration time.Duration, recovered any)
+		// Inflight will be called with delta = 1 when the function is called and delta = -1 when it returns.
+		Inflight(ctx context.Context, name, pkg, intr, method string, delta int)
 		// Total will be called as soon as the function is called.
 		Total(ctx context.Context, name, pkg, intr, method string)
 	},
+	// clock times calls, i.e. a misura.Clock. If nil time.Now will be used.
+	clock interface{ Now() time.Time },
 ) *IPUtilPrometheusWrapperImpl {
+	if wrapped == nil {
+		panic("misura: NewIPUtilPrometheusWrapperImpl: wrapped is nil")
+	}
+
+	if metrics == nil {
+		metrics = noopIPUtilMetrics{}
+	}
+
+	if clock == nil {
+		clock = systemIPUtilClock{}
+	}
+
 	var intr string
 	splited := strings.Split(fmt.Sprintf("%T", wrapped), ".")
 	if len(splited) != 2 {
@@ -58,6 +114,7 @@ func NewIPUtilPrometheusWrapperImpl(
 		intr:    intr,
 		wrapped: wrapped,
 		metrics: metrics,
+		clock:   clock,
 	}
 }
 
@@ -65,11 +122,18 @@ func NewIPUtilPrometheusWrapperImpl(
 // adds prometheus metrics. See PublicIP on IPUtilPrometheusWrapperImpl.wrapped for
 // more information.
 func (w *IPUtilPrometheusWrapperImpl) PublicIP() (net.IP, error) {
-	// TODO time package conflicts
-	start2C37461E := time.Now()
+	start2C37461E := w.clock.Now()
 	w.metrics.Total(context.Background(), w.name, "main", w.intr, "PublicIP")
+	w.metrics.Inflight(context.Background(), w.name, "main", w.intr, "PublicIP", 1)
+	defer w.metrics.Inflight(context.Background(), w.name, "main", w.intr, "PublicIP", -1)
+	defer func() {
+		if r := recover(); r != nil {
+			w.metrics.Panic(context.Background(), w.name, "main", w.intr, "PublicIP", w.clock.Now().Sub(start2C37461E), r)
+			panic(r)
+		}
+	}()
 	a, err := w.wrapped.PublicIP()
-	duration2C37461E := time.Since(start2C37461E)
+	duration2C37461E := w.clock.Now().Sub(start2C37461E)
 	if err != nil {
 		w.metrics.Failure(context.Background(), w.name, "main", w.intr, "PublicIP", duration2C37461E, err)
 		// TODO find a way to add default values here and return the error. for now return the same thing :)
@@ -84,11 +148,18 @@ func (w *IPUtilPrometheusWrapperImpl) PublicIP() (net.IP, error) {
 // adds prometheus metrics. See LocalIPs on IPUtilPrometheusWrapperImpl.wrapped for
 // more information.
 func (w *IPUtilPrometheusWrapperImpl) LocalIPs() ([]net.IP, error) {
-	// TODO time package conflicts
-	start2C37461E := time.Now()
+	start2C37461E := w.clock.Now()
 	w.metrics.Total(context.Background(), w.name, "main", w.intr, "LocalIPs")
+	w.metrics.Inflight(context.Background(), w.name, "main", w.intr, "LocalIPs", 1)
+	defer w.metrics.Inflight(context.Background(), w.name, "main", w.intr, "LocalIPs", -1)
+	defer func() {
+		if r := recover(); r != nil {
+			w.metrics.Panic(context.Background(), w.name, "main", w.intr, "LocalIPs", w.clock.Now().Sub(start2C37461E), r)
+			panic(r)
+		}
+	}()
 	a, err := w.wrapped.LocalIPs()
-	duration2C37461E := time.Since(start2C37461E)
+	duration2C37461E := w.clock.Now().Sub(start2C37461E)
 	if err != nil {
 		w.metrics.Failure(context.Background(), w.name, "main", w.intr, "LocalIPs", duration2C37461E, err)
 		// TODO find a way to add default values here and return the error. for now return the same thing :)
@@ -98,3 +169,94 @@ func (w *IPUtilPrometheusWrapperImpl) LocalIPs() ([]net.IP, error) {
 
 	return a, err
 }
+
+// IPUtilFaultWrapperImpl wraps IPUtil and injects faults chosen by
+// misura.FaultInjector into calls. Rules are matched against IPUtil
+// and method names. Methods without an error result only get latency
+// and panics.
+type IPUtilFaultWrapperImpl struct {
+	name     string
+	intr     string
+	wrapped  IPUtil
+	injector *misura.FaultInjector
+	metrics  interface {
+		// Injected will be called when a fault is injected into a call.
+		Injected(ctx context.Context, name, pkg, intr, method string, fault misura.Fault)
+	}
+}
+
+var _ IPUtil = (*IPUtilFaultWrapperImpl)(nil)
+
+// noopIPUtilFaultMetrics is used by NewIPUtilFaultWrapperImpl when metrics is nil
+// and does nothing.
+type noopIPUtilFaultMetrics struct{}
+
+func (noopIPUtilFaultMetrics) Injected(ctx context.Context, name, pkg, intr, method string, fault misura.Fault) {
+}
+
+// NewIPUtilFaultWrapperImpl creates a IPUtilFaultWrapperImpl. If metrics is nil, calls are
+// not recorded. If injector is nil, no faults are injected. It panics if wrapped is nil.
+func NewIPUtilFaultWrapperImpl(
+	name string,
+	wrapped IPUtil,
+	injector *misura.FaultInjector,
+	metrics interface {
+		// Injected will be called when a fault is injected into a call.
+		Injected(ctx context.Context, name, pkg, intr, method string, fault misura.Fault)
+	},
+) *IPUtilFaultWrapperImpl {
+	if wrapped == nil {
+		panic("misura: NewIPUtilFaultWrapperImpl: wrapped is nil")
+	}
+
+	if metrics == nil {
+		metrics = noopIPUtilFaultMetrics{}
+	}
+
+	if injector == nil {
+		injector = misura.NewFaultInjector()
+	}
+	var intr string
+	splited := strings.Split(fmt.Sprintf("%T", wrapped), ".")
+	if len(splited) != 2 {
+		intr = "IPUtil"
+	} else {
+		intr = splited[1]
+	}
+
+	return &IPUtilFaultWrapperImpl{
+		name:     name,
+		intr:     intr,
+		wrapped:  wrapped,
+		injector: injector,
+		metrics:  metrics,
+	}
+}
+
+// PublicIP calls PublicIP on IPUtilFaultWrapperImpl.wrapped
+// unless an injected fault prevents it.
+func (w *IPUtilFaultWrapperImpl) PublicIP() (a net.IP, err error) {
+	if fault2C37461E, inject2C37461E := w.injector.Pick("IPUtil", "PublicIP"); inject2C37461E {
+		w.metrics.Injected(context.Background(), w.name, "main", w.intr, "PublicIP", fault2C37461E)
+		if err = fault2C37461E.Apply(context.Background(), w.injector.Clock); err != nil {
+			return a, err
+		}
+	}
+
+	a, err = w.wrapped.PublicIP()
+	return a, err
+}
+
+// LocalIPs calls LocalIPs on IPUtilFaultWrapperImpl.wrapped
+// unless an injected fault prevents it.
+func (w *IPUtilFaultWrapperImpl) LocalIPs() (a []net.IP, err error) {
+	if fault2C37461E, inject2C37461E := w.injector.Pick("IPUtil", "LocalIPs"); inject2C37461E {
+		w.metrics.Injected(context.Background(), w.name, "main", w.intr, "LocalIPs", fault2C37461E)
+		if err = fault2C37461E.Apply(context.Background(), w.injector.Clock); err != nil {
+			return a, err
+		}
+	}
+
+	a, err = w.wrapped.LocalIPs()
+	return a, err
+}
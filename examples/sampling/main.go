@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/itzloop/misura/misura"
+)
+
+// TODO find a better way to call with magic comment
+// TODO either by putting a binary in PATH or sth else
+//
+//go:generate misura -m all,panic,inflight -sample
+
+//misura:Counter
+type Counter interface {
+	Inc(ctx context.Context, n int) (int, error)
+	Value() int
+}
+
+type CounterImpl struct {
+	v atomic.Int64
+}
+
+func (c *CounterImpl) Inc(_ context.Context, n int) (int, error) {
+	return int(c.v.Add(int64(n))), nil
+}
+
+func (c *CounterImpl) Value() int {
+	return int(c.v.Load())
+}
+
+// Metrics counts calls, scaling sampled calls by their weight.
+type Metrics struct {
+	*misura.InflightGauge
+
+	// counters hold float64 bits
+	total   atomic.Uint64
+	success atomic.Uint64
+	failure atomic.Uint64
+}
+
+func (m *Metrics) Total(ctx context.Context, name, pkg, intr, method string) {
+	add(&m.total, misura.SampleWeight(ctx))
+}
+
+func (m *Metrics) Success(ctx context.Context, name, pkg, intr, method string, d time.Duration) {
+	add(&m.success, misura.SampleWeight(ctx))
+}
+
+func (m *Metrics) Failure(ctx context.Context, name, pkg, intr, method string, d time.Duration, err error) {
+	add(&m.failure, misura.SampleWeight(ctx))
+}
+
+func (m *Metrics) Panic(ctx context.Context, name, pkg, intr, method string, d time.Duration, recovered any) {
+}
+
+func (m *Metrics) String() string {
+	return fmt.Sprintf("total: %.0f\nsuccess: %.0f\nfailure: %.0f",
+		math.Float64frombits(m.total.Load()),
+		math.Float64frombits(m.success.Load()),
+		math.Float64frombits(m.failure.Load()),
+	)
+}
+
+func add(c *atomic.Uint64, delta float64) {
+	for {
+		old := c.Load()
+		if c.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func main() {
+	m := &Metrics{InflightGauge: misura.NewInflightGauge()}
+
+	// record 1% of Inc calls and every Value call
+	sampler := misura.MethodSampler{
+		Methods: map[string]misura.Sampler{"Inc": misura.RateSampler(0.01)},
+	}
+
+	c := NewCounterPrometheusWrapperImpl("counter", &CounterImpl{}, m, nil, sampler)
+	for i := 0; i < 100000; i++ {
+		c.Inc(context.Background(), 1)
+	}
+	c.Value()
+
+	fmt.Println(m.String())
+}
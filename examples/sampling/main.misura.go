@@ -0,0 +1,194 @@
+// Code generated by github.com/itzloop/misura. DO NOT EDIT!
+// RANDOM_HEX=3DD1057E
+// This is used to avoid name colision. Storing this and then
+// using it will help us avoid unnecessary changes that will
+// pollute git changes.
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itzloop/misura/misura"
+)
+
+// CounterPrometheusWrapperImpl wraps Counter and adds metrics like:
+// 1. success count
+// 2. error count
+// 3. total count
+// 4. duration
+type CounterPrometheusWrapperImpl struct {
+	// TODO what are fields are required
+	name    string
+	intr    string
+	wrapped Counter
+	sampler misura.Sampler
+	clock   interface{ Now() time.Time }
+	metrics interface {
+		// Failure will be called when err != nil passing the duration and err to it
+		Failure(ctx context.Context, name, pkg, intr, method string, duration time.Duration, err error)
+		// Success will be called if err == nil passing the duration
+		Success(ctx context.Context, name, pkg, intr, method string, duration time.Duration)
+		// Panic will be called when the wrapped method panics passing the duration and recovered value to it
+		Panic(ctx context.Context, name, pkg, intr, method string, duration time.Duration, recovered any)
+		// Inflight will be called with delta = 1 when the function is called and delta = -1 when it returns.
+		Inflight(ctx context.Context, name, pkg, intr, method string, delta int)
+		// Total will be called as soon as the function is called.
+		Total(ctx context.Context, name, pkg, intr, method string)
+	}
+}
+
+var _ Counter = (*CounterPrometheusWrapperImpl)(nil)
+
+// noopCounterMetrics is used by NewCounterPrometheusWrapperImpl
+// when metrics is nil and does nothing.
+type noopCounterMetrics struct{}
+
+func (noopCounterMetrics) Failure(ctx context.Context, name, pkg, intr, method string, duration time.Duration, err error) {
+}
+
+func (noopCounterMetrics) Success(ctx context.Context, name, pkg, intr, method string, duration time.Duration) {
+}
+
+func (noopCounterMetrics) Panic(ctx context.Context, name, pkg, intr, method string, duration time.Duration, recovered any) {
+}
+
+func (noopCounterMetrics) Inflight(ctx context.Context, name, pkg, intr, method string, delta int) {
+}
+
+func (noopCounterMetrics) Total(ctx context.Context, name, pkg, intr, method string) {
+}
+
+// systemCounterClock is used by NewCounterPrometheusWrapperImpl
+// when clock is nil.
+type systemCounterClock struct{}
+
+func (systemCounterClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewCounterPrometheusWrapperImpl creates a CounterPrometheusWrapperImpl. If metrics is nil, calls are
+// not recorded. It panics if wrapped is nil.
+func NewCounterPrometheusWrapperImpl(
+	name string,
+	wrapped Counter,
+	metrics interface {
+		// Failure will be called when err != nil passing the duration and err to it
+		Failure(ctx context.Context, name, pkg, intr, method string, duration time.Duration, err error)
+		// Success will be called if err == nil passing the duration
+		Success(ctx context.Context, name, pkg, intr, method string, duration time.Duration)
+		// Panic will be called when the wrapped method panics passing the duration and recovered value to it
+		Panic(ctx context.Context, name, pkg, intr, method string, duration time.Duration, recovered any)
+		// Inflight will be called with delta = 1 when the function is called and delta = -1 when it returns.
+		Inflight(ctx context.Context, name, pkg, intr, method string, delta int)
+		// Total will be called as soon as the function is called.
+		Total(ctx context.Context, name, pkg, intr, method string)
+	},
+	// clock times calls, i.e. a misura.Clock. If nil time.Now will be used.
+	clock interface{ Now() time.Time },
+	// sampler decides which calls are recorded.
+	// If nil every call will be recorded.
+	sampler misura.Sampler,
+) *CounterPrometheusWrapperImpl {
+	if wrapped == nil {
+		panic("misura: NewCounterPrometheusWrapperImpl: wrapped is nil")
+	}
+
+	if metrics == nil {
+		metrics = noopCounterMetrics{}
+	}
+
+	if clock == nil {
+		clock = systemCounterClock{}
+	}
+
+	var intr string
+	splited := strings.Split(fmt.Sprintf("%T", wrapped), ".")
+	if len(splited) != 2 {
+		intr = "Counter"
+	} else {
+		intr = splited[1]
+	}
+
+	if sampler == nil {
+		sampler = misura.AlwaysSample()
+	}
+
+	return &CounterPrometheusWrapperImpl{
+		name:    name,
+		intr:    intr,
+		wrapped: wrapped,
+		metrics: metrics,
+		clock:   clock,
+		sampler: sampler,
+	}
+}
+
+// Inc wraps another instance of Counter and
+// adds prometheus metrics. See Inc on CounterPrometheusWrapperImpl.wrapped for
+// more information.
+func (w *CounterPrometheusWrapperImpl) Inc(ctx context.Context, n int) (int, error) {
+	rate3DD1057E, sampled3DD1057E := w.sampler.Sample("Inc")
+	if !sampled3DD1057E {
+		// in-flight calls are counted for every call to keep the gauge accurate
+		w.metrics.Inflight(ctx, w.name, "main", w.intr, "Inc", 1)
+		defer w.metrics.Inflight(ctx, w.name, "main", w.intr, "Inc", -1)
+		return w.wrapped.Inc(ctx, n)
+	}
+
+	ctx3DD1057E := misura.WithSampleRate(ctx, rate3DD1057E)
+
+	start3DD1057E := w.clock.Now()
+	w.metrics.Total(ctx3DD1057E, w.name, "main", w.intr, "Inc")
+	w.metrics.Inflight(ctx3DD1057E, w.name, "main", w.intr, "Inc", 1)
+	defer w.metrics.Inflight(ctx3DD1057E, w.name, "main", w.intr, "Inc", -1)
+	defer func() {
+		if r := recover(); r != nil {
+			w.metrics.Panic(ctx3DD1057E, w.name, "main", w.intr, "Inc", w.clock.Now().Sub(start3DD1057E), r)
+			panic(r)
+		}
+	}()
+	a, err := w.wrapped.Inc(ctx, n)
+	duration3DD1057E := w.clock.Now().Sub(start3DD1057E)
+	if err != nil {
+		w.metrics.Failure(ctx3DD1057E, w.name, "main", w.intr, "Inc", duration3DD1057E, err)
+		// TODO find a way to add default values here and return the error. for now return the same thing :)
+		return a, err
+	}
+	w.metrics.Success(ctx3DD1057E, w.name, "main", w.intr, "Inc", duration3DD1057E)
+
+	return a, err
+}
+
+// Value wraps another instance of Counter and
+// adds prometheus metrics. See Value on CounterPrometheusWrapperImpl.wrapped for
+// more information.
+func (w *CounterPrometheusWrapperImpl) Value() int {
+	rate3DD1057E, sampled3DD1057E := w.sampler.Sample("Value")
+	if !sampled3DD1057E {
+		// in-flight calls are counted for every call to keep the gauge accurate
+		w.metrics.Inflight(context.Background(), w.name, "main", w.intr, "Value", 1)
+		defer w.metrics.Inflight(context.Background(), w.name, "main", w.intr, "Value", -1)
+		return w.wrapped.Value()
+	}
+
+	ctx3DD1057E := misura.WithSampleRate(context.Background(), rate3DD1057E)
+
+	start3DD1057E := w.clock.Now()
+	w.metrics.Total(ctx3DD1057E, w.name, "main", w.intr, "Value")
+	w.metrics.Inflight(ctx3DD1057E, w.name, "main", w.intr, "Value", 1)
+	defer w.metrics.Inflight(ctx3DD1057E, w.name, "main", w.intr, "Value", -1)
+	defer func() {
+		if r := recover(); r != nil {
+			w.metrics.Panic(ctx3DD1057E, w.name, "main", w.intr, "Value", w.clock.Now().Sub(start3DD1057E), r)
+			panic(r)
+		}
+	}()
+	a := w.wrapped.Value()
+	duration3DD1057E := w.clock.Now().Sub(start3DD1057E)
+	w.metrics.Success(ctx3DD1057E, w.name, "main", w.intr, "Value", duration3DD1057E)
+
+	return a
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/itzloop/misura/misura"
+)
+
+// BenchmarkInc shows the overhead of the wrapper with and without sampling:
+//
+//	go test -bench . -benchmem ./examples/sampling
+func BenchmarkInc(b *testing.B) {
+	cases := []struct {
+		name    string
+		counter func() Counter
+	}{
+		{name: "unwrapped", counter: func() Counter {
+			return &CounterImpl{}
+		}},
+		{name: "always", counter: func() Counter {
+			return NewCounterPrometheusWrapperImpl("counter", &CounterImpl{}, &Metrics{InflightGauge: misura.NewInflightGauge()}, nil, nil)
+		}},
+		{name: "rate_0.1", counter: func() Counter {
+			return NewCounterPrometheusWrapperImpl("counter", &CounterImpl{}, &Metrics{InflightGauge: misura.NewInflightGauge()}, nil, misura.RateSampler(0.1))
+		}},
+		{name: "rate_0.01", counter: func() Counter {
+			return NewCounterPrometheusWrapperImpl("counter", &CounterImpl{}, &Metrics{InflightGauge: misura.NewInflightGauge()}, nil, misura.RateSampler(0.01))
+		}},
+	}
+
+	ctx := context.Background()
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			counter := c.counter()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				counter.Inc(ctx, 1)
+			}
+		})
+	}
+}
@@ -62,6 +62,10 @@ func main() {
 		*cfg.Measures = append(*cfg.Measures, "all")
 	}
 
+	if len(*cfg.Wrappers) == 0 {
+		*cfg.Wrappers = append(*cfg.Wrappers, "metrics")
+	}
+
 	if os.Getenv("GOFILE") != "" {
 		*cfg.FilePath = os.Getenv("GOFILE")
 		fmt.Println("using GOFILE=", *cfg.FilePath)
@@ -78,9 +82,19 @@ func main() {
 	fmt.Printf("generating wrapper for '%s'\n", *cfg.FilePath)
 
 	generator, err := wrapper.NewWrapperGenerator(wrapper.GeneratorOpts{
-		Metrics:       []string(*cfg.Measures),
-		FormatImports: *cfg.FormatImports,
-		Template:      templates(),
+		Metrics:           []string(*cfg.Measures),
+		Wrappers:          []string(*cfg.Wrappers),
+		FormatImports:     *cfg.FormatImports,
+		Capture:           *cfg.Capture,
+		RecoverPanics:     *cfg.Recover,
+		ClassifyErrors:    *cfg.Classify,
+		Sample:            *cfg.Sample,
+		ConstructorErrors: *cfg.CtorError,
+		Bench:             *cfg.Bench,
+		Contract:          *cfg.Contract,
+		SkipErrorless:     *cfg.SkipErrorless,
+		Options:           *cfg.Options,
+		Template:          templates(),
 	})
 	if err != nil {
 		log.Fatalf("failed to create WrapperGenerator: %v\n", err)
@@ -0,0 +1,319 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a Breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every call with ErrCircuitOpen.
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of probes through to decide
+	// whether the breaker should be closed again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerScope decides which calls share a Breaker.
+type BreakerScope int
+
+const (
+	// BreakerPerMethod uses a separate Breaker for each method.
+	BreakerPerMethod BreakerScope = iota
+	// BreakerPerInterface shares a single Breaker between all methods.
+	BreakerPerInterface
+)
+
+// ErrCircuitOpen is returned by generated breaker wrappers instead of
+// calling the wrapped method while the breaker is open.
+type ErrCircuitOpen struct {
+	// State of the breaker when the call was rejected. Either
+	// BreakerOpen or BreakerHalfOpen when all probes are in flight.
+	State BreakerState
+
+	// RetryAfter is the remaining time until the breaker
+	// lets probes through.
+	RetryAfter time.Duration
+}
+
+func (e ErrCircuitOpen) Error() string {
+	return fmt.Sprintf("misura: circuit %s, retry after %s", e.State, e.RetryAfter)
+}
+
+// BreakerPolicy configures a Breaker. Zero values are replaced with defaults.
+type BreakerPolicy struct {
+	// Scope decides which calls share a Breaker. Defaults to BreakerPerMethod.
+	Scope BreakerScope
+
+	// ConsecutiveFailures trips the breaker after that many failures
+	// in a row. Defaults to 5.
+	ConsecutiveFailures int
+
+	// FailureRate, if set, trips the breaker when the ratio of failures
+	// in the current Window exceeds it. Must be in (0, 1].
+	FailureRate float64
+
+	// MinRequests is the minimum number of calls in the current Window
+	// before FailureRate is considered. Defaults to 10.
+	MinRequests int
+
+	// Window is the interval in which calls are counted for FailureRate
+	// while closed. Defaults to 1m.
+	Window time.Duration
+
+	// OpenTimeout is how long the breaker stays open before letting
+	// probes through. Defaults to 30s.
+	OpenTimeout time.Duration
+
+	// HalfOpenProbes is the number of successful probes needed to close
+	// the breaker. It's also the maximum number of concurrent probes.
+	// Defaults to 1.
+	HalfOpenProbes int
+
+	// IsFailure decides if an error counts as a failure. By default all
+	// errors except context cancellation and ErrCircuitOpen are failures.
+	IsFailure func(err error) bool
+
+	// Clock defaults to SystemClock.
+	Clock Clock
+}
+
+func (p BreakerPolicy) withDefaults() BreakerPolicy {
+	if p.ConsecutiveFailures <= 0 {
+		p.ConsecutiveFailures = 5
+	}
+
+	if p.MinRequests <= 0 {
+		p.MinRequests = 10
+	}
+
+	if p.Window <= 0 {
+		p.Window = time.Minute
+	}
+
+	if p.OpenTimeout <= 0 {
+		p.OpenTimeout = 30 * time.Second
+	}
+
+	if p.HalfOpenProbes <= 0 {
+		p.HalfOpenProbes = 1
+	}
+
+	if p.IsFailure == nil {
+		p.IsFailure = defaultIsFailure
+	}
+
+	if p.Clock == nil {
+		p.Clock = SystemClock{}
+	}
+
+	return p
+}
+
+func defaultIsFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.As(err, &ErrCircuitOpen{})
+}
+
+// Breaker is a circuit breaker. It's safe for concurrent use.
+type Breaker struct {
+	policy BreakerPolicy
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64
+	consecutive int
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probes      int
+	successes   int
+}
+
+// NewBreaker creates a closed Breaker.
+func NewBreaker(policy BreakerPolicy) *Breaker {
+	policy = policy.withDefaults()
+	return &Breaker{
+		policy:      policy,
+		windowStart: policy.Clock.Now(),
+	}
+}
+
+// State returns the current state of b.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// transitions are applied by Allow so they can be reported
+	if b.state == BreakerOpen && !b.policy.Clock.Now().Before(b.openedAt.Add(b.policy.OpenTimeout)) {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// Allow checks whether a call can go through. If it can, done must be called
+// with the result of the call. Otherwise an ErrCircuitOpen is returned.
+// onChange, if not nil, is called on state transitions caused by this call
+// while b is locked, so it must not call b.
+func (b *Breaker) Allow(onChange func(from, to BreakerState)) (done func(err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.policy.Clock.Now()
+	b.refresh(now, onChange)
+
+	switch b.state {
+	case BreakerOpen:
+		return nil, ErrCircuitOpen{State: BreakerOpen, RetryAfter: b.openedAt.Add(b.policy.OpenTimeout).Sub(now)}
+	case BreakerHalfOpen:
+		if b.probes >= b.policy.HalfOpenProbes {
+			return nil, ErrCircuitOpen{State: BreakerHalfOpen}
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	return func(err error) {
+		b.done(generation, err, onChange)
+	}, nil
+}
+
+func (b *Breaker) done(generation uint64, err error, onChange func(from, to BreakerState)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the state has changed since the call was allowed
+	if generation != b.generation {
+		return
+	}
+
+	now := b.policy.Clock.Now()
+	failed := b.policy.IsFailure(err)
+
+	switch b.state {
+	case BreakerClosed:
+		b.requests++
+		if !failed {
+			b.consecutive = 0
+			return
+		}
+
+		b.failures++
+		b.consecutive++
+		if b.consecutive >= b.policy.ConsecutiveFailures || b.rateExceeded() {
+			b.setState(BreakerOpen, now, onChange)
+		}
+	case BreakerHalfOpen:
+		b.probes--
+		if failed {
+			b.setState(BreakerOpen, now, onChange)
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.policy.HalfOpenProbes {
+			b.setState(BreakerClosed, now, onChange)
+		}
+	}
+}
+
+func (b *Breaker) rateExceeded() bool {
+	return b.policy.FailureRate > 0 &&
+		b.requests >= b.policy.MinRequests &&
+		float64(b.failures)/float64(b.requests) >= b.policy.FailureRate
+}
+
+// refresh applies time based transitions.
+func (b *Breaker) refresh(now time.Time, onChange func(from, to BreakerState)) {
+	switch b.state {
+	case BreakerOpen:
+		if !now.Before(b.openedAt.Add(b.policy.OpenTimeout)) {
+			b.setState(BreakerHalfOpen, now, onChange)
+		}
+	case BreakerClosed:
+		if !now.Before(b.windowStart.Add(b.policy.Window)) {
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+	}
+}
+
+func (b *Breaker) setState(to BreakerState, now time.Time, onChange func(from, to BreakerState)) {
+	from := b.state
+	b.state = to
+	b.generation++
+	b.consecutive = 0
+	b.requests = 0
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+	b.windowStart = now
+	if to == BreakerOpen {
+		b.openedAt = now
+	}
+
+	if onChange != nil {
+		onChange(from, to)
+	}
+}
+
+// BreakerGroup holds breakers of a generated wrapper according
+// to BreakerPolicy.Scope.
+type BreakerGroup struct {
+	policy   BreakerPolicy
+	shared   *Breaker
+	breakers sync.Map
+}
+
+// NewBreakerGroup creates a BreakerGroup.
+func NewBreakerGroup(policy BreakerPolicy) *BreakerGroup {
+	g := &BreakerGroup{policy: policy}
+	if policy.Scope == BreakerPerInterface {
+		g.shared = NewBreaker(policy)
+	}
+
+	return g
+}
+
+// Get returns the Breaker of method.
+func (g *BreakerGroup) Get(method string) *Breaker {
+	if g.shared != nil {
+		return g.shared
+	}
+
+	if b, ok := g.breakers.Load(method); ok {
+		return b.(*Breaker)
+	}
+
+	b, _ := g.breakers.LoadOrStore(method, NewBreaker(g.policy))
+	return b.(*Breaker)
+}
+
+// Allow calls Allow on the Breaker of method.
+func (g *BreakerGroup) Allow(method string, onChange func(from, to BreakerState)) (done func(err error), err error) {
+	return g.Get(method).Allow(onChange)
+}
@@ -0,0 +1,124 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestBreaker(t *testing.T) {
+	errBoom := errors.New("boom")
+	clock := &testClock{now: time.Unix(0, 0)}
+	b := NewBreaker(BreakerPolicy{
+		ConsecutiveFailures: 2,
+		OpenTimeout:         time.Second,
+		Clock:               clock,
+	})
+
+	var changes []BreakerState
+	onChange := func(_, to BreakerState) {
+		changes = append(changes, to)
+	}
+
+	call := func(err error) error {
+		done, openErr := b.Allow(onChange)
+		if openErr != nil {
+			return openErr
+		}
+		done(err)
+		return nil
+	}
+
+	require.NoError(t, call(errBoom))
+	require.NoError(t, call(nil), "success resets consecutive failures")
+	require.NoError(t, call(errBoom))
+	require.NoError(t, call(errBoom))
+	assert.Equal(t, BreakerOpen, b.State())
+
+	err := call(nil)
+	var open ErrCircuitOpen
+	require.ErrorAs(t, err, &open)
+	assert.Equal(t, BreakerOpen, open.State)
+	assert.Equal(t, time.Second, open.RetryAfter)
+
+	clock.now = clock.now.Add(time.Second)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+
+	// only one probe at a time
+	done, err := b.Allow(onChange)
+	require.NoError(t, err)
+	_, err = b.Allow(onChange)
+	require.ErrorAs(t, err, &open)
+	assert.Equal(t, BreakerHalfOpen, open.State)
+
+	done(errBoom)
+	assert.Equal(t, BreakerOpen, b.State(), "failed probe opens the breaker")
+
+	clock.now = clock.now.Add(time.Second)
+	require.NoError(t, call(nil))
+	assert.Equal(t, BreakerClosed, b.State())
+
+	assert.Equal(t, []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}, changes)
+}
+
+func TestBreakerFailureRate(t *testing.T) {
+	errBoom := errors.New("boom")
+	clock := &testClock{now: time.Unix(0, 0)}
+	b := NewBreaker(BreakerPolicy{
+		ConsecutiveFailures: 100,
+		FailureRate:         0.5,
+		MinRequests:         4,
+		Window:              time.Minute,
+		Clock:               clock,
+	})
+
+	for _, err := range []error{errBoom, nil, errBoom} {
+		done, allowErr := b.Allow(nil)
+		require.NoError(t, allowErr)
+		done(err)
+	}
+	assert.Equal(t, BreakerClosed, b.State(), "not enough requests")
+
+	// a new window resets the counts
+	clock.now = clock.now.Add(time.Minute)
+	for _, err := range []error{errBoom, nil, nil} {
+		done, allowErr := b.Allow(nil)
+		require.NoError(t, allowErr)
+		done(err)
+	}
+	assert.Equal(t, BreakerClosed, b.State())
+
+	done, err := b.Allow(nil)
+	require.NoError(t, err)
+	done(errBoom)
+	assert.Equal(t, BreakerOpen, b.State())
+}
+
+func TestBreakerIgnoresCanceled(t *testing.T) {
+	b := NewBreaker(BreakerPolicy{ConsecutiveFailures: 1})
+	done, err := b.Allow(nil)
+	require.NoError(t, err)
+	done(context.Canceled)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestBreakerGroup(t *testing.T) {
+	perMethod := NewBreakerGroup(BreakerPolicy{})
+	assert.Same(t, perMethod.Get("Get"), perMethod.Get("Get"))
+	assert.NotSame(t, perMethod.Get("Get"), perMethod.Get("Set"))
+
+	perInterface := NewBreakerGroup(BreakerPolicy{Scope: BreakerPerInterface})
+	assert.Same(t, perInterface.Get("Get"), perInterface.Get("Set"))
+}
@@ -0,0 +1,160 @@
+package misura
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CachePolicy configures caches of generated cache wrappers.
+type CachePolicy struct {
+	// TTL is used for methods annotated with //misura:cache
+	// without a value. Defaults to 1m.
+	TTL time.Duration
+
+	// MaxEntries of each method. The least recently used entry
+	// is evicted when it's reached. Zero means no limit.
+	MaxEntries int
+
+	// Clock defaults to SystemClock.
+	Clock Clock
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+type cacheCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Cache is an in-process LRU cache with TTL and singleflight de-duplication
+// of concurrent loads of the same key. It's safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	ttl    time.Duration
+	policy CachePolicy
+
+	mu      sync.Mutex
+	entries map[K]*list.Element
+	lru     *list.List
+	calls   map[K]*cacheCall[V]
+}
+
+// NewCache creates a Cache. ttl overrides policy.TTL if it's positive.
+func NewCache[K comparable, V any](policy CachePolicy, ttl time.Duration) *Cache[K, V] {
+	if policy.TTL <= 0 {
+		policy.TTL = time.Minute
+	}
+
+	if ttl <= 0 {
+		ttl = policy.TTL
+	}
+
+	if policy.Clock == nil {
+		policy.Clock = SystemClock{}
+	}
+
+	return &Cache[K, V]{
+		ttl:     ttl,
+		policy:  policy,
+		entries: map[K]*list.Element{},
+		lru:     list.New(),
+		calls:   map[K]*cacheCall[V]{},
+	}
+}
+
+// Get returns the cached value of key. Otherwise load is called, and its value
+// is cached if it returns without an error. Concurrent calls with the same key
+// wait for a single load and share its result. hit is true for calls served
+// from the cache or by a load of another call that succeeded. If load panics,
+// the panic is propagated and waiting calls get a *PanicError.
+func (c *Cache[K, V]) Get(key K, load func() (V, error)) (value V, hit bool, err error) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry[K, V])
+		if c.policy.Clock.Now().Before(entry.expires) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return entry.value, true, nil
+		}
+
+		c.remove(el)
+	}
+
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.value, call.err == nil, call.err
+	}
+
+	call := &cacheCall[V]{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	completed := false
+	defer func() {
+		// load panicked or called runtime.Goexit
+		if !completed {
+			r := recover()
+			call.err = NewPanicError(r)
+			if r != nil {
+				defer panic(r)
+			}
+		}
+
+		c.mu.Lock()
+		delete(c.calls, key)
+		if call.err == nil {
+			c.add(key, call.value)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = load()
+	completed = true
+	return call.value, false, call.err
+}
+
+// Len returns the number of cached entries, including expired ones
+// that are not removed yet.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Purge removes all cached entries.
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[K]*list.Element{}
+	c.lru.Init()
+}
+
+func (c *Cache[K, V]) add(key K, value V) {
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry[K, V]{
+		key:     key,
+		value:   value,
+		expires: c.policy.Clock.Now().Add(c.ttl),
+	})
+
+	if c.policy.MaxEntries > 0 && c.lru.Len() > c.policy.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache[K, V]) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry[K, V]).key)
+}
@@ -0,0 +1,142 @@
+package misura
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	clock := &testClock{now: time.Unix(0, 0)}
+	c := NewCache[string, int](CachePolicy{MaxEntries: 2, Clock: clock}, time.Second)
+
+	loads := 0
+	load := func(v int) func() (int, error) {
+		return func() (int, error) {
+			loads++
+			return v, nil
+		}
+	}
+
+	v, hit, err := c.Get("a", load(1))
+	require.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, 1, v)
+
+	v, hit, _ = c.Get("a", load(2))
+	assert.True(t, hit)
+	assert.Equal(t, 1, v)
+
+	clock.now = clock.now.Add(time.Second)
+	v, hit, _ = c.Get("a", load(2))
+	assert.False(t, hit, "expired")
+	assert.Equal(t, 2, v)
+
+	// b and c evict a
+	c.Get("b", load(3))
+	c.Get("c", load(4))
+	assert.Equal(t, 2, c.Len())
+	_, hit, _ = c.Get("a", load(5))
+	assert.False(t, hit)
+	assert.Equal(t, 5, loads)
+
+	c.Purge()
+	assert.Zero(t, c.Len())
+}
+
+func TestCacheErrorsAreNotCached(t *testing.T) {
+	c := NewCache[string, int](CachePolicy{}, 0)
+
+	_, _, err := c.Get("a", func() (int, error) { return 0, errors.New("boom") })
+	require.Error(t, err)
+
+	v, hit, err := c.Get("a", func() (int, error) { return 1, nil })
+	require.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, 1, v)
+}
+
+func TestCachePanicsAreNotCached(t *testing.T) {
+	c := NewCache[string, int](CachePolicy{}, 0)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		c.Get("a", func() (int, error) { panic("boom") })
+	})
+	assert.Zero(t, c.Len())
+
+	v, hit, err := c.Get("a", func() (int, error) { return 1, nil })
+	require.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, 1, v)
+}
+
+func TestCacheSharedFailure(t *testing.T) {
+	c := NewCache[string, int](CachePolicy{}, 0)
+
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() { recover() }()
+		c.Get("a", func() (int, error) {
+			close(loading)
+			<-release
+			panic("boom")
+		})
+	}()
+
+	<-loading
+	results := make(chan error)
+	go func() {
+		_, hit, err := c.Get("a", func() (int, error) { return 1, nil })
+		assert.False(t, hit)
+		results <- err
+	}()
+
+	// wait for the second call to share the load
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	<-done
+
+	var panicErr *PanicError
+	require.ErrorAs(t, <-results, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	c := NewCache[string, int](CachePolicy{}, 0)
+
+	var loads atomic.Int64
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	var hits atomic.Int64
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, hit, err := c.Get("a", func() (int, error) {
+				loads.Add(1)
+				<-release
+				return 42, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 42, v)
+			if hit {
+				hits.Add(1)
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, loads.Load())
+	assert.EqualValues(t, 9, hits.Load())
+}
@@ -0,0 +1,75 @@
+package misura
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// RedactedValue replaces the value of fields that are redacted
+// using //misura:redact annotation.
+const RedactedValue = "[REDACTED]"
+
+// Redacted can be implemented by types holding sensitive data. When such
+// a value is captured, the result of Redacted will be recorded instead.
+type Redacted interface {
+	Redacted() any
+}
+
+// Field is a captured parameter or result of a call.
+type Field struct {
+	Name  string
+	Value any
+}
+
+// Capture creates a Field for v. If v implements Redacted,
+// v.Redacted() will be used as the value. Nil pointers are
+// captured as is since calling Redacted on them may panic.
+func Capture(name string, v any) Field {
+	if r, ok := v.(Redacted); ok && !isNilPointer(v) {
+		return Field{Name: name, Value: r.Redacted()}
+	}
+
+	return Field{Name: name, Value: v}
+}
+
+func isNilPointer(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+// Redact creates a Field with RedactedValue as its value.
+func Redact(name string) Field {
+	return Field{Name: name, Value: RedactedValue}
+}
+
+// CallInfo describes a single call to a wrapped method.
+type CallInfo struct {
+	Name   string
+	Pkg    string
+	Intr   string
+	Method string
+
+	// Params and Results are only populated when the wrapper
+	// is generated with -capture.
+	Params  []Field
+	Results []Field
+
+	// Timeout is the value of //misura:timeout of the method, zero if it's
+	// not annotated. It's only set by interceptor wrappers.
+	Timeout time.Duration
+}
+
+type callInfoKey struct{}
+
+// WithCallInfo returns a copy of ctx carrying info. Generated wrappers
+// use this to pass captured values to metrics.
+func WithCallInfo(ctx context.Context, info CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// CallInfoFromContext returns the CallInfo stored in ctx, if any.
+func CallInfoFromContext(ctx context.Context) (CallInfo, bool) {
+	info, ok := ctx.Value(callInfoKey{}).(CallInfo)
+	return info, ok
+}
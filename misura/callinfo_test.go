@@ -0,0 +1,20 @@
+package misura
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapture(t *testing.T) {
+	assert.Equal(t, Field{Name: "id", Value: "42"}, Capture("id", "42"))
+	assert.Equal(t, Field{Name: "name", Value: "s***"}, Capture("name", secret("sina")))
+
+	var nilSecret *secret
+	assert.NotPanics(t, func() {
+		assert.Equal(t, Field{Name: "name", Value: nilSecret}, Capture("name", nilSecret))
+	})
+
+	s := secret("sina")
+	assert.Equal(t, Field{Name: "name", Value: "s***"}, Capture("name", &s))
+}
@@ -0,0 +1,197 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Error classes returned by built-in classifiers.
+const (
+	ClassCanceled         = "canceled"
+	ClassDeadlineExceeded = "deadline_exceeded"
+	ClassTimeout          = "timeout"
+	ClassNotExist         = "not_exist"
+	ClassNotOK            = "not_ok"
+	ClassInjected         = "injected"
+	ClassUnknown          = "unknown"
+)
+
+// ErrorClassifier maps errors to low-cardinality labels. Classify should return
+// an empty string if it can't classify err so other classifiers can be tried.
+type ErrorClassifier interface {
+	Classify(err error) string
+}
+
+// ErrorClassifierFunc is an adapter to use ordinary functions as ErrorClassifier.
+type ErrorClassifierFunc func(err error) string
+
+func (f ErrorClassifierFunc) Classify(err error) string {
+	return f(err)
+}
+
+// ChainClassifiers returns an ErrorClassifier that tries classifiers in order and
+// returns the first non-empty class. If none of them can classify the error
+// ClassUnknown is returned.
+func ChainClassifiers(classifiers ...ErrorClassifier) ErrorClassifier {
+	return ErrorClassifierFunc(func(err error) string {
+		for _, c := range classifiers {
+			if class := c.Classify(err); class != "" {
+				return class
+			}
+		}
+
+		return ClassUnknown
+	})
+}
+
+// DefaultErrorClassifier chains all built-in classifiers.
+func DefaultErrorClassifier() ErrorClassifier {
+	return ChainClassifiers(
+		MisuraClassifier(),
+		ContextClassifier(),
+		NetTimeoutClassifier(),
+		NotExistClassifier(),
+		GRPCClassifier(),
+	)
+}
+
+// MisuraClassifier classifies errors returned by misura itself.
+func MisuraClassifier() ErrorClassifier {
+	return ErrorClassifierFunc(func(err error) string {
+		var timeout *TimeoutError
+		switch {
+		case errors.Is(err, ErrNotOK):
+			return ClassNotOK
+		case errors.As(err, &timeout):
+			return ClassTimeout
+		case errors.Is(err, ErrInjected):
+			return ClassInjected
+		}
+
+		return ""
+	})
+}
+
+// ContextClassifier classifies context.Canceled and context.DeadlineExceeded.
+func ContextClassifier() ErrorClassifier {
+	return ErrorClassifierFunc(func(err error) string {
+		switch {
+		case errors.Is(err, context.Canceled):
+			return ClassCanceled
+		case errors.Is(err, context.DeadlineExceeded):
+			return ClassDeadlineExceeded
+		}
+
+		return ""
+	})
+}
+
+// NetTimeoutClassifier classifies net.Error timeouts.
+func NetTimeoutClassifier() ErrorClassifier {
+	return ErrorClassifierFunc(func(err error) string {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return ClassTimeout
+		}
+
+		return ""
+	})
+}
+
+// NotExistClassifier classifies os.ErrNotExist.
+func NotExistClassifier() ErrorClassifier {
+	return ErrorClassifierFunc(func(err error) string {
+		if errors.Is(err, os.ErrNotExist) {
+			return ClassNotExist
+		}
+
+		return ""
+	})
+}
+
+// GRPCClassifier classifies errors returned by google.golang.org/grpc/status
+// as grpc_<code> (i.e. grpc_not_found). To avoid depending on grpc, errors
+// are matched by their GRPCStatus().Code() method.
+func GRPCClassifier() ErrorClassifier {
+	return ErrorClassifierFunc(func(err error) string {
+		code, ok := findGRPCCode(err)
+		if !ok || code == "OK" {
+			return ""
+		}
+
+		return "grpc_" + snakeCase(code)
+	})
+}
+
+// findGRPCCode returns the code of the first error in the tree of err with
+// a GRPCStatus method. The tree is walked in the same order as errors.As,
+// including errors joined using errors.Join.
+func findGRPCCode(err error) (string, bool) {
+	for err != nil {
+		if code, ok := grpcCode(err); ok {
+			return code, true
+		}
+
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err := range u.Unwrap() {
+				if code, ok := findGRPCCode(err); ok {
+					return code, true
+				}
+			}
+
+			return "", false
+		default:
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+func grpcCode(err error) (string, bool) {
+	m := reflect.ValueOf(err).MethodByName("GRPCStatus")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return "", false
+	}
+
+	status := m.Call(nil)[0]
+	if status.Kind() == reflect.Pointer && status.IsNil() {
+		return "", false
+	}
+
+	code := status.MethodByName("Code")
+	if !code.IsValid() || code.Type().NumIn() != 0 || code.Type().NumOut() != 1 {
+		return "", false
+	}
+
+	v, ok := code.Call(nil)[0].Interface().(interface{ String() string })
+	if !ok {
+		return "", false
+	}
+
+	return v.String(), true
+}
+
+// snakeCase converts codes like NotFound to not_found.
+func snakeCase(s string) string {
+	b := strings.Builder{}
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
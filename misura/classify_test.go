@@ -0,0 +1,71 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCode and fakeStatus mimic grpc codes.Code and *status.Status.
+type fakeCode uint32
+
+func (c fakeCode) String() string {
+	return []string{"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded", "NotFound"}[c]
+}
+
+type fakeStatus struct{ code fakeCode }
+
+func (s *fakeStatus) Code() fakeCode { return s.code }
+
+type fakeGRPCError struct{ status *fakeStatus }
+
+func (e fakeGRPCError) Error() string           { return "rpc error" }
+func (e fakeGRPCError) GRPCStatus() *fakeStatus { return e.status }
+
+func TestDefaultErrorClassifier(t *testing.T) {
+	c := DefaultErrorClassifier()
+	cases := []struct {
+		err      error
+		expected string
+	}{
+		{err: ErrNotOK, expected: ClassNotOK},
+		{err: context.Canceled, expected: ClassCanceled},
+		{err: fmt.Errorf("wrapped: %w", context.DeadlineExceeded), expected: ClassDeadlineExceeded},
+		{err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, expected: ClassTimeout},
+		{err: &os.PathError{Op: "open", Path: "/nope", Err: os.ErrNotExist}, expected: ClassNotExist},
+		{err: fakeGRPCError{status: &fakeStatus{code: 5}}, expected: "grpc_not_found"},
+		{err: fmt.Errorf("wrapped: %w", fakeGRPCError{status: &fakeStatus{code: 3}}), expected: "grpc_invalid_argument"},
+		{err: errors.Join(errors.New("first"), fakeGRPCError{status: &fakeStatus{code: 5}}), expected: "grpc_not_found"},
+		{err: fmt.Errorf("wrapped: %w", errors.Join(fakeGRPCError{}, fakeGRPCError{status: &fakeStatus{code: 3}})), expected: "grpc_invalid_argument"},
+		{err: fakeGRPCError{status: &fakeStatus{code: 0}}, expected: ClassUnknown},
+		{err: fakeGRPCError{}, expected: ClassUnknown},
+		{err: errors.New("boom"), expected: ClassUnknown},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.err.Error(), func(t *testing.T) {
+			assert.Equal(t, cs.expected, c.Classify(cs.err))
+		})
+	}
+}
+
+func TestChainClassifiers(t *testing.T) {
+	errCustom := errors.New("custom")
+	c := ChainClassifiers(
+		ErrorClassifierFunc(func(err error) string {
+			if errors.Is(err, errCustom) {
+				return "custom"
+			}
+			return ""
+		}),
+		DefaultErrorClassifier(),
+	)
+
+	assert.Equal(t, "custom", c.Classify(errCustom))
+	assert.Equal(t, ClassCanceled, c.Classify(context.Canceled))
+}
@@ -0,0 +1,133 @@
+package misura
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provides the current time. It can be replaced in tests
+// to control time based behaviors.
+type Clock interface {
+	Now() time.Time
+}
+
+// Timer is a timer created by a TimerClock.
+type Timer interface {
+	// C receives the time once the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It reports
+	// whether the timer was stopped before it fired.
+	Stop() bool
+}
+
+// TimerClock is a Clock which can also create timers. Backoffs, delays and
+// deadlines use timers of the Clock passed to them when it's a TimerClock,
+// otherwise they wait in real time.
+type TimerClock interface {
+	Clock
+	NewTimer(d time.Duration) Timer
+}
+
+// SystemClock is a Clock backed by time.Now. Times returned by it carry
+// a monotonic reading, so durations are not affected by changes of the
+// wall clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// newTimer creates a timer using clock if it's a TimerClock.
+func newTimer(clock Clock, d time.Duration) Timer {
+	if tc, ok := clock.(TimerClock); ok {
+		return tc.NewTimer(d)
+	}
+
+	return SystemClock{}.NewTimer(d)
+}
+
+// WithTimeout is like context.WithTimeout but the deadline is decided by
+// clock. If clock is nil or SystemClock, context.WithTimeout is used.
+func WithTimeout(ctx context.Context, clock Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := clock.(TimerClock); !ok || clock == (SystemClock{}) {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	cancelCtx, cancel := context.WithCancelCause(ctx)
+	c := &clockDeadlineCtx{
+		Context:  cancelCtx,
+		deadline: clock.Now().Add(timeout),
+		done:     make(chan struct{}),
+	}
+
+	timer := newTimer(clock, timeout)
+	go func() {
+		defer close(c.done)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C():
+			cancel(context.DeadlineExceeded)
+		case <-cancelCtx.Done():
+		}
+	}()
+
+	return c, func() {
+		cancel(nil)
+		<-c.done
+	}
+}
+
+// clockDeadlineCtx is canceled when the timer of a Clock fires and
+// reports context.DeadlineExceeded like contexts with a deadline. It has
+// its own done channel, so contexts derived from it are canceled using
+// Err instead of the canceled cancelCtx, and report
+// context.DeadlineExceeded as well.
+type clockDeadlineCtx struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+}
+
+func (c *clockDeadlineCtx) Deadline() (time.Time, bool) {
+	if parent, ok := c.Context.Deadline(); ok && parent.Before(c.deadline) {
+		return parent, true
+	}
+
+	return c.deadline, true
+}
+
+func (c *clockDeadlineCtx) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *clockDeadlineCtx) Err() error {
+	select {
+	case <-c.done:
+	default:
+		return nil
+	}
+
+	if context.Cause(c.Context) == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+
+	return c.Context.Err()
+}
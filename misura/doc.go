@@ -0,0 +1,4 @@
+// Package misura is the runtime library for code generated by
+// github.com/itzloop/misura. It contains ready to use backends
+// for the metrics interface that generated wrappers accept.
+package misura
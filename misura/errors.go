@@ -0,0 +1,11 @@
+package misura
+
+import "errors"
+
+// ErrNotOK is passed to Failure when the result of a method annotated
+// with //misura:ok is false and the miss measure is not enabled.
+var ErrNotOK = errors.New("misura: result is not ok")
+
+// ErrNilWrapped is returned by constructors of wrappers generated with
+// -ctor-error when wrapped is nil.
+var ErrNilWrapped = errors.New("misura: wrapped is nil")
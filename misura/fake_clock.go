@@ -0,0 +1,123 @@
+package misura
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a TimerClock for tests. Time only moves when Advance or Set
+// is called, firing timers that are due. It's safe for concurrent use.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer creates a timer firing once the clock is advanced by d.
+// Timers with d <= 0 fire immediately.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{
+		clock:    c,
+		c:        make(chan time.Time, 1),
+		deadline: c.now.Add(d),
+	}
+
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d and fires timers that are due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	now := c.now.Add(d)
+	c.mu.Unlock()
+
+	c.Set(now)
+}
+
+// Set sets the clock to now and fires timers that are due.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(now) {
+			pending = append(pending, t)
+			continue
+		}
+
+		t.c <- now
+	}
+
+	c.timers = pending
+}
+
+// Timers returns the number of timers that have not fired or stopped.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// BlockUntil blocks until there are at least n timers that have not
+// fired or stopped. Use it before Advance to make sure a goroutine is
+// waiting on the clock.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
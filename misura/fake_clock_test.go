@@ -0,0 +1,214 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	first := clock.NewTimer(time.Second)
+	second := clock.NewTimer(2 * time.Second)
+	stopped := clock.NewTimer(time.Second)
+	assert.Equal(t, 3, clock.Timers())
+	assert.True(t, stopped.Stop())
+	assert.Equal(t, 2, clock.Timers())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), clock.Now())
+	assert.Equal(t, start.Add(time.Second), <-first.C())
+	assert.False(t, first.Stop())
+	assert.Empty(t, second.C())
+
+	clock.Set(start.Add(time.Minute))
+	assert.Equal(t, start.Add(time.Minute), <-second.C())
+	assert.Zero(t, clock.Timers())
+
+	assert.Equal(t, start.Add(time.Minute), <-clock.NewTimer(0).C())
+}
+
+func TestFakeClockBlockUntil(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	done := make(chan struct{})
+	go func() {
+		<-clock.NewTimer(time.Second).C()
+		close(done)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-done
+}
+
+func TestWithTimeout(t *testing.T) {
+	t.Run("fake_clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		ctx, cancel := WithTimeout(context.Background(), clock, time.Second)
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.Equal(t, time.Unix(1, 0), deadline)
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Second - 1)
+		assert.NoError(t, ctx.Err())
+
+		clock.Advance(1)
+		<-ctx.Done()
+		assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+		assert.True(t, TimedOut(context.Background(), ctx))
+	})
+
+	t.Run("fake_clock_derived", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		ctx, cancel := WithTimeout(context.Background(), clock, time.Second)
+		defer cancel()
+
+		child, cancelChild := context.WithCancel(ctx)
+		defer cancelChild()
+		value := context.WithValue(child, struct{}{}, "value")
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		<-value.Done()
+		assert.ErrorIs(t, child.Err(), context.DeadlineExceeded)
+		assert.ErrorIs(t, value.Err(), context.DeadlineExceeded)
+		assert.ErrorIs(t, context.Cause(ctx), context.DeadlineExceeded)
+		assert.ErrorIs(t, context.Cause(value), context.DeadlineExceeded)
+	})
+
+	t.Run("fake_clock_parent_canceled", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		parent, cancelParent := context.WithCancel(context.Background())
+		ctx, cancel := WithTimeout(parent, clock, time.Second)
+		defer cancel()
+
+		child, cancelChild := context.WithCancel(ctx)
+		defer cancelChild()
+
+		cancelParent()
+		<-child.Done()
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+		assert.ErrorIs(t, child.Err(), context.Canceled)
+	})
+
+	t.Run("fake_clock_canceled", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		ctx, cancel := WithTimeout(context.Background(), clock, time.Second)
+		cancel()
+
+		<-ctx.Done()
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+		assert.False(t, TimedOut(context.Background(), ctx))
+	})
+
+	t.Run("system_clock", func(t *testing.T) {
+		ctx, cancel := WithTimeout(context.Background(), nil, time.Millisecond)
+		defer cancel()
+
+		<-ctx.Done()
+		assert.True(t, errors.Is(ctx.Err(), context.DeadlineExceeded))
+	})
+}
+
+func TestRetryFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	policy := RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour, Clock: clock}
+
+	var durations []time.Duration
+	done := make(chan error)
+	go func() {
+		done <- Retry(context.Background(), policy, func() error {
+			return errors.New("temporary")
+		}, func(_ int, duration time.Duration, _ error) {
+			durations = append(durations, duration)
+		})
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	require.Error(t, <-done)
+	assert.Equal(t, []time.Duration{0, 0}, durations)
+}
+
+func TestFaultFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	done := make(chan error)
+	go func() {
+		done <- Fault{Latency: Duration(time.Hour)}.Apply(context.Background(), clock)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	require.NoError(t, <-done)
+}
+
+// notifyClock notifies calls to Now, so tests can advance
+// the clock after a start time is taken.
+type notifyClock struct {
+	*FakeClock
+	calls chan struct{}
+}
+
+func (c notifyClock) Now() time.Time {
+	now := c.FakeClock.Now()
+	c.calls <- struct{}{}
+	return now
+}
+
+func TestSemaphoreFakeClock(t *testing.T) {
+	clock := notifyClock{FakeClock: NewFakeClock(time.Unix(0, 0)), calls: make(chan struct{}, 2)}
+	s := NewSemaphore(1, true)
+	s.Clock = clock
+
+	_, release, err := s.Acquire(context.Background())
+	require.NoError(t, err)
+
+	waited := make(chan time.Duration)
+	go func() {
+		d, _, err := s.Acquire(context.Background())
+		assert.NoError(t, err)
+		waited <- d
+	}()
+
+	<-clock.calls
+	clock.Advance(time.Second)
+	release()
+	assert.Equal(t, time.Second, <-waited)
+}
+
+type durationMetrics struct {
+	durations []time.Duration
+}
+
+func (m *durationMetrics) Failure(_ context.Context, _, _, _, _ string, duration time.Duration, _ error) {
+	m.durations = append(m.durations, duration)
+}
+
+func (m *durationMetrics) Success(_ context.Context, _, _, _, _ string, duration time.Duration) {
+	m.durations = append(m.durations, duration)
+}
+
+func (m *durationMetrics) Total(context.Context, string, string, string, string) {}
+
+func TestMetricsInterceptorFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	metrics := &durationMetrics{}
+	interceptor := MetricsInterceptor(metrics, clock)
+
+	err := interceptor(context.Background(), CallInfo{Method: "Get"}, func(context.Context) error {
+		clock.Advance(time.Second)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Second}, metrics.durations)
+}
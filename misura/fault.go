@@ -0,0 +1,193 @@
+package misura
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables read by FaultInjector.LoadEnv.
+const (
+	// FaultsEnv holds fault rules as a JSON array.
+	FaultsEnv = "MISURA_FAULTS"
+	// FaultsFileEnv holds the path of a JSON file containing fault rules.
+	FaultsFileEnv = "MISURA_FAULTS_FILE"
+)
+
+// ErrInjected is wrapped by errors injected by FaultInjector.
+var ErrInjected = errors.New("misura: injected fault")
+
+// InjectedError is returned by generated fault wrappers when
+// a Fault with an Error is injected.
+type InjectedError struct {
+	Message string
+}
+
+func (e *InjectedError) Error() string {
+	return e.Message
+}
+
+func (e *InjectedError) Unwrap() error {
+	return ErrInjected
+}
+
+// Fault is a rule of FaultInjector. Latency is applied first, then
+// the call panics if Panic is set or fails if Error is set.
+type Fault struct {
+	// Intr and Method choose calls this rule applies to.
+	// Empty values match everything.
+	Intr   string `json:"intr,omitempty"`
+	Method string `json:"method,omitempty"`
+
+	// Probability of injecting the fault in [0, 1].
+	Probability float64 `json:"probability"`
+
+	// Latency is added before calling the wrapped method.
+	Latency Duration `json:"latency,omitempty"`
+
+	// Panic, if set, is the value passed to panic.
+	Panic string `json:"panic,omitempty"`
+
+	// Error, if set, is the message of the InjectedError
+	// returned instead of calling the wrapped method.
+	Error string `json:"error,omitempty"`
+}
+
+// Duration is a time.Duration which is encoded
+// as a string in JSON, i.e. "150ms".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+func (f Fault) matches(intr, method string) bool {
+	return (f.Intr == "" || f.Intr == intr) && (f.Method == "" || f.Method == method)
+}
+
+// Apply applies the fault to a call. It waits for Latency honoring ctx,
+// then panics or returns an *InjectedError if Panic or Error are set.
+// Latency is waited using timers of clock if it's a TimerClock.
+func (f Fault) Apply(ctx context.Context, clock Clock) error {
+	if f.Latency > 0 {
+		timer := newTimer(clock, time.Duration(f.Latency))
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+
+	if f.Panic != "" {
+		panic(f.Panic)
+	}
+
+	if f.Error != "" {
+		return &InjectedError{Message: f.Error}
+	}
+
+	return nil
+}
+
+// FaultInjector decides which faults are injected into calls of generated
+// fault wrappers. Rules can be replaced at runtime and it's safe for
+// concurrent use.
+type FaultInjector struct {
+	// Clock is used to wait for Latency of faults. Defaults to SystemClock.
+	// It must not be changed after the injector is used.
+	Clock Clock
+
+	mu    sync.RWMutex
+	rules []Fault
+}
+
+// NewFaultInjector creates a FaultInjector with rules.
+func NewFaultInjector(rules ...Fault) *FaultInjector {
+	return &FaultInjector{Clock: SystemClock{}, rules: rules}
+}
+
+// SetRules replaces the rules of i. Passing no rules disables injection.
+func (i *FaultInjector) SetRules(rules ...Fault) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.rules = rules
+}
+
+// Rules returns the current rules of i.
+func (i *FaultInjector) Rules() []Fault {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return append([]Fault(nil), i.rules...)
+}
+
+// LoadEnv replaces the rules of i with the ones in FaultsEnv or the file
+// in FaultsFileEnv. Rules are not changed if neither of them is set.
+func (i *FaultInjector) LoadEnv() error {
+	if rules := strings.TrimSpace(os.Getenv(FaultsEnv)); rules != "" {
+		return i.Load(strings.NewReader(rules))
+	}
+
+	if path := os.Getenv(FaultsFileEnv); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return i.Load(f)
+	}
+
+	return nil
+}
+
+// Load replaces the rules of i with a JSON array of rules read from r.
+func (i *FaultInjector) Load(r io.Reader) error {
+	var rules []Fault
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return fmt.Errorf("misura: failed to load fault rules: %w", err)
+	}
+
+	i.SetRules(rules...)
+	return nil
+}
+
+// Pick returns the first matching rule that should be injected
+// into a call, according to its probability.
+func (i *FaultInjector) Pick(intr, method string) (Fault, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, f := range i.rules {
+		if f.matches(intr, method) && rand.Float64() < f.Probability {
+			return f, true
+		}
+	}
+
+	return Fault{}, false
+}
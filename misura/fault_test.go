@@ -0,0 +1,58 @@
+package misura
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjector(t *testing.T) {
+	i := NewFaultInjector(
+		Fault{Method: "Get", Probability: 1, Error: "boom"},
+		Fault{Intr: "Cache", Probability: 0, Error: "never"},
+	)
+
+	f, ok := i.Pick("Store", "Get")
+	require.True(t, ok)
+	err := f.Apply(context.Background(), nil)
+	require.ErrorIs(t, err, ErrInjected)
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, ClassInjected, DefaultErrorClassifier().Classify(err))
+
+	_, ok = i.Pick("Store", "List")
+	assert.False(t, ok)
+	_, ok = i.Pick("Cache", "List")
+	assert.False(t, ok, "probability is zero")
+
+	i.SetRules()
+	_, ok = i.Pick("Store", "Get")
+	assert.False(t, ok)
+}
+
+func TestFaultApply(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := Fault{Latency: Duration(time.Hour)}.Apply(ctx, nil)
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.NoError(t, Fault{Latency: Duration(time.Millisecond)}.Apply(context.Background(), nil))
+	assert.PanicsWithValue(t, "chaos", func() {
+		_ = Fault{Panic: "chaos"}.Apply(context.Background(), nil)
+	})
+}
+
+func TestFaultInjectorLoad(t *testing.T) {
+	i := NewFaultInjector()
+	require.NoError(t, i.Load(strings.NewReader(`[{"method": "Get", "probability": 0.5, "latency": "150ms"}]`)))
+	assert.Equal(t, []Fault{{Method: "Get", Probability: 0.5, Latency: Duration(150 * time.Millisecond)}}, i.Rules())
+
+	t.Setenv(FaultsEnv, `[{"probability": 1, "error": "boom"}]`)
+	require.NoError(t, i.LoadEnv())
+	assert.Equal(t, []Fault{{Probability: 1, Error: "boom"}}, i.Rules())
+
+	require.Error(t, i.Load(strings.NewReader(`[{"latency": "soon"}]`)))
+}
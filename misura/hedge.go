@@ -0,0 +1,197 @@
+package misura
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgePolicy configures when generated hedge wrappers fire a second call.
+type HedgePolicy struct {
+	// Delay is used for methods annotated with //misura:hedge without
+	// a value, until there are enough observed latencies. Defaults to 100ms.
+	Delay time.Duration
+
+	// Percentile of observed latencies used as the delay, i.e. 0.95.
+	// Zero disables observing latencies. Must be in [0, 1].
+	Percentile float64
+
+	// Window is the number of recent latencies kept for each method.
+	// Defaults to 100.
+	Window int
+
+	// MinSamples is the number of observed latencies required before
+	// Percentile is used. Defaults to 10.
+	MinSamples int
+
+	// Clock is used to time calls and wait for the delay.
+	// Defaults to SystemClock.
+	Clock Clock
+}
+
+// Hedger decides the delay of hedged calls and observes their latencies.
+// It's safe for concurrent use.
+type Hedger struct {
+	policy HedgePolicy
+
+	mu        sync.Mutex
+	latencies map[string]*latencyWindow
+}
+
+// NewHedger creates a Hedger.
+func NewHedger(policy HedgePolicy) *Hedger {
+	if policy.Delay <= 0 {
+		policy.Delay = 100 * time.Millisecond
+	}
+
+	policy.Percentile = math.Max(0, math.Min(policy.Percentile, 1))
+
+	if policy.Window <= 0 {
+		policy.Window = 100
+	}
+
+	if policy.MinSamples <= 0 {
+		policy.MinSamples = 10
+	}
+
+	if policy.Clock == nil {
+		policy.Clock = SystemClock{}
+	}
+
+	return &Hedger{
+		policy:    policy,
+		latencies: map[string]*latencyWindow{},
+	}
+}
+
+// Delay returns the delay before a second call of method is fired.
+// annotated is the value of //misura:hedge or zero if it has no value.
+func (h *Hedger) Delay(method string, annotated time.Duration) time.Duration {
+	if h.policy.Percentile > 0 {
+		h.mu.Lock()
+		w := h.latencies[method]
+		if w != nil && len(w.samples) >= h.policy.MinSamples {
+			d := w.percentile(h.policy.Percentile)
+			h.mu.Unlock()
+			return d
+		}
+		h.mu.Unlock()
+	}
+
+	if annotated > 0 {
+		return annotated
+	}
+
+	return h.policy.Delay
+}
+
+// Observe records the latency of a call to method.
+func (h *Hedger) Observe(method string, latency time.Duration) {
+	if h.policy.Percentile == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w, ok := h.latencies[method]
+	if !ok {
+		w = &latencyWindow{samples: make([]time.Duration, 0, h.policy.Window)}
+		h.latencies[method] = w
+	}
+
+	w.add(latency)
+}
+
+// Hedge calls fn and, if it hasn't returned after the delay of method, calls
+// it again. The result of whichever returns first is returned and the ctx of
+// the other one is canceled. hedged reports whether the second call was fired
+// and won whether its result was returned. The observed latency is measured
+// from the start of the first call, so a winning second call is observed as
+// a lower bound of the latency of the first one. If the call that returns
+// first panics, the panic is propagated to the caller of Hedge.
+func Hedge[T any](ctx context.Context, h *Hedger, method string, annotated time.Duration, fn func(ctx context.Context) T) (result T, hedged, won bool) {
+	type hedgeResult struct {
+		value     T
+		hedge     bool
+		latency   time.Duration
+		panicked  bool
+		recovered any
+	}
+
+	// buffered so the loser doesn't block after it's canceled
+	results := make(chan hedgeResult, 2)
+	start := h.policy.Clock.Now()
+	call := func(ctx context.Context, hedge bool) {
+		r := hedgeResult{hedge: hedge}
+		completed := false
+		defer func() {
+			// fn panicked or called runtime.Goexit
+			if !completed {
+				r.panicked = true
+				if r.recovered = recover(); r.recovered == nil {
+					r.recovered = NewPanicError(nil)
+				}
+			}
+
+			r.latency = h.policy.Clock.Now().Sub(start)
+			results <- r
+		}()
+
+		r.value = fn(ctx)
+		completed = true
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(ctx)
+	defer cancelFirst()
+	go call(firstCtx, false)
+
+	timer := newTimer(h.policy.Clock, h.Delay(method, annotated))
+	defer timer.Stop()
+
+	var r hedgeResult
+	select {
+	case r = <-results:
+	case <-timer.C():
+		hedgeCtx, cancelHedge := context.WithCancel(ctx)
+		defer cancelHedge()
+		go call(hedgeCtx, true)
+
+		hedged = true
+		r = <-results
+	}
+
+	if r.panicked {
+		panic(r.recovered)
+	}
+
+	h.Observe(method, r.latency)
+	return r.value, hedged, r.hedge
+}
+
+// latencyWindow keeps the most recent latencies in a ring.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	if len(w.samples) < cap(w.samples) {
+		w.samples = append(w.samples, latency)
+		return
+	}
+
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % len(w.samples)
+}
+
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	sorted := make([]time.Duration, len(w.samples))
+	copy(sorted, w.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
@@ -0,0 +1,129 @@
+package misura
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedgerDelay(t *testing.T) {
+	h := NewHedger(HedgePolicy{Delay: time.Second, Percentile: 0.9, MinSamples: 10})
+	assert.Equal(t, time.Second, h.Delay("Get", 0))
+	assert.Equal(t, time.Minute, h.Delay("Get", time.Minute))
+
+	for i := 1; i <= 10; i++ {
+		h.Observe("Get", time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 9*time.Millisecond, h.Delay("Get", time.Minute), "observed latencies override annotations")
+	assert.Equal(t, time.Second, h.Delay("List", 0))
+
+	h = NewHedger(HedgePolicy{Window: 2, Percentile: 1, MinSamples: 1})
+	h.Observe("Get", time.Second)
+	h.Observe("Get", time.Millisecond)
+	h.Observe("Get", time.Millisecond)
+	assert.Equal(t, time.Millisecond, h.Delay("Get", 0), "old latencies are dropped")
+}
+
+func TestHedgeFast(t *testing.T) {
+	h := NewHedger(HedgePolicy{Delay: time.Second})
+
+	var calls atomic.Int64
+	v, hedged, won := Hedge(context.Background(), h, "Get", 0, func(ctx context.Context) int {
+		calls.Add(1)
+		return 1
+	})
+	assert.Equal(t, 1, v)
+	assert.False(t, hedged)
+	assert.False(t, won)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestHedgeWon(t *testing.T) {
+	h := NewHedger(HedgePolicy{Delay: time.Millisecond})
+
+	var calls atomic.Int64
+	canceled := make(chan struct{})
+	v, hedged, won := Hedge(context.Background(), h, "Get", 0, func(ctx context.Context) int {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			close(canceled)
+			return 1
+		}
+
+		return 2
+	})
+	assert.Equal(t, 2, v)
+	assert.True(t, hedged)
+	assert.True(t, won)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the first call was not canceled")
+	}
+}
+
+func TestHedgeLost(t *testing.T) {
+	h := NewHedger(HedgePolicy{Delay: time.Millisecond})
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	v, hedged, won := Hedge(context.Background(), h, "Get", 0, func(ctx context.Context) int {
+		if calls.Add(1) == 1 {
+			<-release
+			return 1
+		}
+
+		close(release)
+		<-ctx.Done()
+		return 2
+	})
+	assert.Equal(t, 1, v)
+	assert.True(t, hedged)
+	assert.False(t, won)
+}
+
+func TestHedgeLatency(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	h := NewHedger(HedgePolicy{Delay: time.Second, Percentile: 1, MinSamples: 1, Clock: clock})
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	done := make(chan int)
+	go func() {
+		v, _, _ := Hedge(context.Background(), h, "Get", 0, func(ctx context.Context) int {
+			if calls.Add(1) == 1 {
+				<-ctx.Done()
+				return 1
+			}
+
+			<-release
+			return 2
+		})
+		done <- v
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	for calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(time.Second)
+	close(release)
+	assert.Equal(t, 2, <-done)
+	assert.Equal(t, 2*time.Second, h.Delay("Get", 0), "latency is measured from the start of the first call")
+}
+
+func TestHedgePanic(t *testing.T) {
+	h := NewHedger(HedgePolicy{Delay: time.Second})
+
+	assert.PanicsWithValue(t, "boom", func() {
+		Hedge(context.Background(), h, "Get", 0, func(ctx context.Context) int {
+			panic("boom")
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package misura
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// InflightKey identifies a wrapped method.
+type InflightKey struct {
+	Name   string
+	Pkg    string
+	Intr   string
+	Method string
+}
+
+// InflightGauge tracks the number of in-flight calls per method. It implements
+// Inflight method of the metrics interface and can be embedded in other
+// backends. The zero value is ready to use.
+type InflightGauge struct {
+	counters sync.Map // InflightKey -> *atomic.Int64
+}
+
+// NewInflightGauge creates an InflightGauge.
+func NewInflightGauge() *InflightGauge {
+	return &InflightGauge{}
+}
+
+// Inflight is called with delta = 1 when a call starts and delta = -1 when it ends.
+func (g *InflightGauge) Inflight(_ context.Context, name, pkg, intr, method string, delta int) {
+	g.counter(InflightKey{Name: name, Pkg: pkg, Intr: intr, Method: method}).Add(int64(delta))
+}
+
+// Value returns the number of in-flight calls for the given method.
+func (g *InflightGauge) Value(name, pkg, intr, method string) int64 {
+	c, ok := g.counters.Load(InflightKey{Name: name, Pkg: pkg, Intr: intr, Method: method})
+	if !ok {
+		return 0
+	}
+
+	return c.(*atomic.Int64).Load()
+}
+
+// Snapshot returns the number of in-flight calls for every method seen so far.
+func (g *InflightGauge) Snapshot() map[InflightKey]int64 {
+	snapshot := map[InflightKey]int64{}
+	g.counters.Range(func(k, v any) bool {
+		snapshot[k.(InflightKey)] = v.(*atomic.Int64).Load()
+		return true
+	})
+
+	return snapshot
+}
+
+func (g *InflightGauge) counter(key InflightKey) *atomic.Int64 {
+	c, ok := g.counters.Load(key)
+	if !ok {
+		c, _ = g.counters.LoadOrStore(key, &atomic.Int64{})
+	}
+
+	return c.(*atomic.Int64)
+}
@@ -0,0 +1,44 @@
+package misura
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInflightGauge(t *testing.T) {
+	var (
+		g     = NewInflightGauge()
+		ctx   = context.Background()
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Inflight(ctx, "n", "pkg", "Intr", "Get", 1)
+			<-start
+			g.Inflight(ctx, "n", "pkg", "Intr", "Get", -1)
+		}()
+	}
+
+	assert.Eventually(t, func() bool {
+		return g.Value("n", "pkg", "Intr", "Get") == 10
+	}, time.Second, time.Millisecond)
+
+	g.Inflight(ctx, "n", "pkg", "Intr", "List", 1)
+	assert.Equal(t, map[InflightKey]int64{
+		{Name: "n", Pkg: "pkg", Intr: "Intr", Method: "Get"}:  10,
+		{Name: "n", Pkg: "pkg", Intr: "Intr", Method: "List"}: 1,
+	}, g.Snapshot())
+
+	close(start)
+	wg.Wait()
+	assert.Zero(t, g.Value("n", "pkg", "Intr", "Get"))
+	assert.Zero(t, g.Value("n", "pkg", "Intr", "Unknown"))
+}
@@ -0,0 +1,142 @@
+package misura
+
+import (
+	"context"
+	"time"
+)
+
+// Interceptor intercepts calls of generated interceptor wrappers. next calls
+// the wrapped method, or the next interceptor in the chain, with the given ctx
+// and returns its error. Interceptors can skip calling next, i.e. to reject a
+// call, or call it multiple times, i.e. to retry.
+type Interceptor func(ctx context.Context, info CallInfo, next func(ctx context.Context) error) error
+
+// Chain composes interceptors into one. The first interceptor is the
+// outermost one. If interceptors is empty, nil is returned.
+func Chain(interceptors ...Interceptor) Interceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+
+	chain := interceptors[len(interceptors)-1]
+	for i := len(interceptors) - 2; i >= 0; i-- {
+		outer, inner := interceptors[i], chain
+		chain = func(ctx context.Context, info CallInfo, next func(ctx context.Context) error) error {
+			return outer(ctx, info, func(ctx context.Context) error {
+				return inner(ctx, info, next)
+			})
+		}
+	}
+
+	return chain
+}
+
+// Interceptors configures generated interceptor wrappers.
+type Interceptors struct {
+	// All is applied to every method.
+	All []Interceptor
+
+	// Methods is applied to methods by name, after All.
+	Methods map[string][]Interceptor
+}
+
+// For returns the chain of method or nil if it has no interceptors.
+func (i Interceptors) For(method string) Interceptor {
+	chain := make([]Interceptor, 0, len(i.All)+len(i.Methods[method]))
+	chain = append(chain, i.All...)
+	chain = append(chain, i.Methods[method]...)
+	return Chain(chain...)
+}
+
+// RetryInterceptor retries calls using policy. Only use it
+// for idempotent methods.
+func RetryInterceptor(policy RetryPolicy) Interceptor {
+	return func(ctx context.Context, _ CallInfo, next func(ctx context.Context) error) error {
+		return Retry(ctx, policy, func() error {
+			return next(ctx)
+		}, nil)
+	}
+}
+
+// BreakerInterceptor rejects calls with ErrCircuitOpen while
+// the breaker of the method is open.
+func BreakerInterceptor(breakers *BreakerGroup) Interceptor {
+	return func(ctx context.Context, info CallInfo, next func(ctx context.Context) error) (err error) {
+		done, err := breakers.Allow(info.Method, nil)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				done(NewPanicError(r))
+				panic(r)
+			}
+			done(err)
+		}()
+
+		return next(ctx)
+	}
+}
+
+// LimitInterceptor limits calls using the Limiter of the method.
+func LimitInterceptor(limiters *LimiterGroup) Interceptor {
+	return func(ctx context.Context, info CallInfo, next func(ctx context.Context) error) error {
+		_, release, err := limiters.Acquire(ctx, info.Method)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return next(ctx)
+	}
+}
+
+// TimeoutInterceptor passes a context with a deadline decided by
+// policy and info.Timeout to next. If the deadline is exceeded,
+// the error is wrapped in a *TimeoutError.
+func TimeoutInterceptor(policy TimeoutPolicy) Interceptor {
+	return func(ctx context.Context, info CallInfo, next func(ctx context.Context) error) error {
+		timeout := policy.Timeout(info.Method, info.Timeout)
+		if timeout <= 0 {
+			return next(ctx)
+		}
+
+		timeoutCtx, cancel := WithTimeout(ctx, policy.Clock, timeout)
+		defer cancel()
+
+		err := next(timeoutCtx)
+		if err != nil && TimedOut(ctx, timeoutCtx) {
+			return &TimeoutError{Timeout: timeout, Err: err}
+		}
+
+		return err
+	}
+}
+
+// MetricsInterceptor reports calls to metrics the same way
+// generated metrics wrappers do. Calls are timed using clock,
+// if it's nil SystemClock is used.
+func MetricsInterceptor(metrics interface {
+	Failure(ctx context.Context, name, pkg, intr, method string, duration time.Duration, err error)
+	Success(ctx context.Context, name, pkg, intr, method string, duration time.Duration)
+	Total(ctx context.Context, name, pkg, intr, method string)
+}, clock Clock) Interceptor {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	return func(ctx context.Context, info CallInfo, next func(ctx context.Context) error) error {
+		start := clock.Now()
+		metrics.Total(ctx, info.Name, info.Pkg, info.Intr, info.Method)
+
+		err := next(ctx)
+		duration := clock.Now().Sub(start)
+		if err != nil {
+			metrics.Failure(ctx, info.Name, info.Pkg, info.Intr, info.Method, duration, err)
+			return err
+		}
+
+		metrics.Success(ctx, info.Name, info.Pkg, info.Intr, info.Method, duration)
+		return nil
+	}
+}
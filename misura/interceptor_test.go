@@ -0,0 +1,81 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, info CallInfo, next func(ctx context.Context) error) error {
+			order = append(order, name+":"+info.Method)
+			return next(ctx)
+		}
+	}
+
+	chain := Interceptors{
+		All:     []Interceptor{record("a"), record("b")},
+		Methods: map[string][]Interceptor{"Get": {record("c")}},
+	}
+
+	err := chain.For("Get")(context.Background(), CallInfo{Method: "Get"}, func(context.Context) error {
+		order = append(order, "call")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a:Get", "b:Get", "c:Get", "call"}, order)
+
+	assert.Nil(t, Interceptors{}.For("Get"))
+}
+
+func TestChainShortCircuit(t *testing.T) {
+	errRejected := errors.New("rejected")
+	reject := func(context.Context, CallInfo, func(context.Context) error) error {
+		return errRejected
+	}
+
+	called := false
+	err := Chain(reject)(context.Background(), CallInfo{}, func(context.Context) error {
+		called = true
+		return nil
+	})
+	require.ErrorIs(t, err, errRejected)
+	assert.False(t, called)
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	calls := 0
+	err := RetryInterceptor(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})(
+		context.Background(), CallInfo{}, func(context.Context) error {
+			calls++
+			return errors.New("boom")
+		})
+	require.Error(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestTimeoutInterceptor(t *testing.T) {
+	interceptor := TimeoutInterceptor(TimeoutPolicy{Default: time.Millisecond})
+	err := interceptor(context.Background(), CallInfo{Method: "Get"}, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	var timeout *TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	assert.Equal(t, time.Millisecond, timeout.Timeout)
+
+	// annotated timeouts are used instead of the default
+	err = interceptor(context.Background(), CallInfo{Method: "Get", Timeout: 2 * time.Millisecond}, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.ErrorAs(t, err, &timeout)
+	assert.Equal(t, 2*time.Millisecond, timeout.Timeout)
+}
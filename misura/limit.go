@@ -0,0 +1,234 @@
+package misura
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter limits calls of generated limit wrappers.
+type Limiter interface {
+	// Acquire returns when the call is allowed, returning the time it had
+	// to wait and a release function that must be called after the call.
+	// Otherwise an error is returned, either because the limiter fails fast
+	// or because ctx is done while waiting.
+	Acquire(ctx context.Context) (waited time.Duration, release func(), err error)
+}
+
+// ErrRateLimited is returned by TokenBucket when there are no tokens left
+// and it doesn't wait, or the wait time exceeds the deadline of ctx.
+type ErrRateLimited struct {
+	// RetryAfter is the time until a token is available. It's zero
+	// if tokens are never added.
+	RetryAfter time.Duration
+}
+
+func (e ErrRateLimited) Error() string {
+	return fmt.Sprintf("misura: rate limited, retry after %s", e.RetryAfter)
+}
+
+// ErrConcurrencyLimited is returned by Semaphore when all slots
+// are in use and it doesn't wait.
+type ErrConcurrencyLimited struct {
+	Limit int
+}
+
+func (e ErrConcurrencyLimited) Error() string {
+	return fmt.Sprintf("misura: concurrency limit of %d reached", e.Limit)
+}
+
+// TokenBucketPolicy configures a TokenBucket.
+type TokenBucketPolicy struct {
+	// Rate is the number of tokens added per second.
+	Rate float64
+
+	// Burst is the maximum number of tokens. Defaults to 1.
+	Burst int
+
+	// Wait, if set to true, waits for a token honoring ctx
+	// instead of failing fast with ErrRateLimited.
+	Wait bool
+
+	// Clock defaults to SystemClock.
+	Clock Clock
+}
+
+// TokenBucket is a token bucket rate Limiter. It's safe for concurrent use.
+type TokenBucket struct {
+	policy TokenBucketPolicy
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a full TokenBucket.
+func NewTokenBucket(policy TokenBucketPolicy) *TokenBucket {
+	if policy.Burst <= 0 {
+		policy.Burst = 1
+	}
+
+	if policy.Clock == nil {
+		policy.Clock = SystemClock{}
+	}
+
+	return &TokenBucket{
+		policy: policy,
+		tokens: float64(policy.Burst),
+		last:   policy.Clock.Now(),
+	}
+}
+
+func (b *TokenBucket) Acquire(ctx context.Context) (time.Duration, func(), error) {
+	wait, err := b.reserve(ctx)
+	if err != nil || wait == 0 {
+		return 0, noop, err
+	}
+
+	timer := newTimer(b.policy.Clock, wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		b.cancel()
+		return wait, noop, ctx.Err()
+	case <-timer.C():
+		return wait, noop, nil
+	}
+}
+
+// reserve takes a token and returns how long to wait for it.
+func (b *TokenBucket) reserve(ctx context.Context) (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.policy.Clock.Now()
+	b.tokens = math.Min(float64(b.policy.Burst), b.tokens+now.Sub(b.last).Seconds()*b.policy.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+
+	// tokens are never added
+	if b.policy.Rate <= 0 {
+		return 0, ErrRateLimited{}
+	}
+
+	wait := time.Duration((1 - b.tokens) / b.policy.Rate * float64(time.Second))
+	if !b.policy.Wait {
+		return 0, ErrRateLimited{RetryAfter: wait}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(wait)) {
+		return 0, ErrRateLimited{RetryAfter: wait}
+	}
+
+	b.tokens--
+	return wait, nil
+}
+
+// cancel gives back a reserved token.
+func (b *TokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = math.Min(float64(b.policy.Burst), b.tokens+1)
+}
+
+// Semaphore limits the number of concurrent calls. It's safe for concurrent use.
+type Semaphore struct {
+	// Clock is used to measure waits. Defaults to SystemClock.
+	// It must not be changed after the first call to Acquire.
+	Clock Clock
+
+	slots chan struct{}
+	wait  bool
+}
+
+// NewSemaphore creates a Semaphore allowing size concurrent calls. If wait is
+// true it waits for a slot honoring ctx instead of failing fast with
+// ErrConcurrencyLimited.
+func NewSemaphore(size int, wait bool) *Semaphore {
+	return &Semaphore{
+		Clock: SystemClock{},
+		slots: make(chan struct{}, max(size, 1)),
+		wait:  wait,
+	}
+}
+
+func (s *Semaphore) Acquire(ctx context.Context) (time.Duration, func(), error) {
+	select {
+	case s.slots <- struct{}{}:
+		return 0, s.release, nil
+	default:
+	}
+
+	if !s.wait {
+		return 0, noop, ErrConcurrencyLimited{Limit: cap(s.slots)}
+	}
+
+	clock := s.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	start := clock.Now()
+	select {
+	case s.slots <- struct{}{}:
+		return clock.Now().Sub(start), s.release, nil
+	case <-ctx.Done():
+		return clock.Now().Sub(start), noop, ctx.Err()
+	}
+}
+
+func (s *Semaphore) release() {
+	<-s.slots
+}
+
+func noop() {}
+
+// LimiterGroup holds limiters of a generated limit wrapper.
+type LimiterGroup struct {
+	// Methods holds limiters by method name. The same Limiter
+	// can be used for multiple methods to share the limit.
+	Methods map[string]Limiter
+
+	// Default, if not nil, is used to create a Limiter for
+	// each method that is not in Methods.
+	Default func() Limiter
+
+	defaults sync.Map
+}
+
+// Get returns the Limiter of method or nil if it's not limited.
+func (g *LimiterGroup) Get(method string) Limiter {
+	if l, ok := g.Methods[method]; ok {
+		return l
+	}
+
+	if g.Default == nil {
+		return nil
+	}
+
+	if l, ok := g.defaults.Load(method); ok {
+		return l.(Limiter)
+	}
+
+	l, _ := g.defaults.LoadOrStore(method, g.Default())
+	return l.(Limiter)
+}
+
+// Acquire calls Acquire on the Limiter of method. Calls
+// to methods without a Limiter are always allowed.
+func (g *LimiterGroup) Acquire(ctx context.Context, method string) (time.Duration, func(), error) {
+	l := g.Get(method)
+	if l == nil {
+		return 0, noop, nil
+	}
+
+	return l.Acquire(ctx)
+}
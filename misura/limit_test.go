@@ -0,0 +1,96 @@
+package misura
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	clock := &testClock{now: time.Unix(0, 0)}
+	b := NewTokenBucket(TokenBucketPolicy{Rate: 2, Burst: 2, Clock: clock})
+
+	for i := 0; i < 2; i++ {
+		waited, _, err := b.Acquire(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, waited)
+	}
+
+	_, _, err := b.Acquire(context.Background())
+	var limited ErrRateLimited
+	require.ErrorAs(t, err, &limited)
+	assert.Equal(t, 500*time.Millisecond, limited.RetryAfter)
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	_, _, err = b.Acquire(context.Background())
+	require.NoError(t, err)
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	b := NewTokenBucket(TokenBucketPolicy{Rate: 100, Wait: true})
+
+	_, _, err := b.Acquire(context.Background())
+	require.NoError(t, err)
+
+	waited, _, err := b.Acquire(context.Background())
+	require.NoError(t, err)
+	assert.Greater(t, waited, time.Duration(0))
+
+	// the deadline is before the next token
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, _, err = b.Acquire(ctx)
+	require.ErrorAs(t, err, &ErrRateLimited{})
+}
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(1, false)
+
+	_, release, err := s.Acquire(context.Background())
+	require.NoError(t, err)
+
+	_, _, err = s.Acquire(context.Background())
+	require.ErrorAs(t, err, &ErrConcurrencyLimited{})
+
+	release()
+	_, _, err = s.Acquire(context.Background())
+	require.NoError(t, err)
+}
+
+func TestSemaphoreWait(t *testing.T) {
+	s := NewSemaphore(1, true)
+
+	_, release, err := s.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, _, err = s.Acquire(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(time.Millisecond)
+		release()
+	}()
+	waited, _, err := s.Acquire(context.Background())
+	require.NoError(t, err)
+	assert.Greater(t, waited, time.Duration(0))
+}
+
+func TestLimiterGroup(t *testing.T) {
+	shared := NewSemaphore(1, false)
+	g := &LimiterGroup{
+		Methods: map[string]Limiter{"Get": shared, "List": shared},
+		Default: func() Limiter { return NewSemaphore(1, false) },
+	}
+
+	assert.Same(t, g.Get("Get"), g.Get("List"))
+	assert.Same(t, g.Get("Put"), g.Get("Put"))
+	assert.NotSame(t, g.Get("Put"), g.Get("Delete"))
+
+	_, _, err := (&LimiterGroup{}).Acquire(context.Background(), "Get")
+	require.NoError(t, err, "methods without a limiter are not limited")
+}
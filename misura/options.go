@@ -0,0 +1,131 @@
+package misura
+
+import "context"
+
+// Options configures metrics wrappers generated with -options. It's
+// populated by Option functions passed to the constructor.
+type Options struct {
+	// Name is passed to metrics as name. Defaults to the name
+	// of the interface.
+	Name string
+
+	// Recorder is the metrics implementation. It's set by the
+	// With<Type>Recorder option generated alongside the wrapper,
+	// which only accepts the metrics required by the wrapper.
+	// If nil, calls are not recorded.
+	Recorder any
+
+	// Clock is used to time calls. Defaults to SystemClock.
+	Clock Clock
+
+	// Sampler is used by wrappers generated with -sample.
+	// Defaults to AlwaysSample.
+	Sampler Sampler
+
+	// Classifier is used by wrappers generated with -classify.
+	// Defaults to DefaultErrorClassifier.
+	Classifier ErrorClassifier
+
+	// Labels are passed to metrics in ctx, see LabelsFromContext.
+	Labels map[string]string
+}
+
+// Option configures a wrapper generated with -options.
+type Option func(*Options)
+
+// NewOptions applies opts and sets defaults. name is used
+// if WithName is not passed.
+func NewOptions(name string, opts ...Option) Options {
+	o := Options{Name: name}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.Clock == nil {
+		o.Clock = SystemClock{}
+	}
+
+	if o.Sampler == nil {
+		o.Sampler = AlwaysSample()
+	}
+
+	if o.Classifier == nil {
+		o.Classifier = DefaultErrorClassifier()
+	}
+
+	return o
+}
+
+// WithName sets the name passed to metrics.
+func WithName(name string) Option {
+	return func(o *Options) {
+		o.Name = name
+	}
+}
+
+// WithClock sets the Clock used to time calls.
+func WithClock(clock Clock) Option {
+	return func(o *Options) {
+		o.Clock = clock
+	}
+}
+
+// WithSampler sets the Sampler of wrappers generated with -sample.
+func WithSampler(sampler Sampler) Option {
+	return func(o *Options) {
+		o.Sampler = sampler
+	}
+}
+
+// WithClassifier sets the ErrorClassifier of wrappers generated with -classify.
+func WithClassifier(classifier ErrorClassifier) Option {
+	return func(o *Options) {
+		o.Classifier = classifier
+	}
+}
+
+// WithLabels adds static labels, i.e. tenant or region, passed to metrics
+// of every call. Labels of later calls override earlier ones.
+func WithLabels(labels map[string]string) Option {
+	return func(o *Options) {
+		if o.Labels == nil {
+			o.Labels = make(map[string]string, len(labels))
+		}
+
+		for k, v := range labels {
+			o.Labels[k] = v
+		}
+	}
+}
+
+// CloneLabels returns a copy of labels with room for n more labels.
+// Generated wrappers use this to add labels extracted from parameters
+// to static labels.
+func CloneLabels(labels map[string]string, n int) map[string]string {
+	clone := make(map[string]string, len(labels)+n)
+	for k, v := range labels {
+		clone[k] = v
+	}
+
+	return clone
+}
+
+type labelsKey struct{}
+
+// ContextWithLabels returns a copy of ctx carrying labels. Generated
+// wrappers use this to pass labels to metrics. ctx is returned as is
+// if there are no labels.
+func ContextWithLabels(ctx context.Context, labels map[string]string) context.Context {
+	if len(labels) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, labelsKey{}, labels)
+}
+
+// LabelsFromContext returns labels stored in ctx, if any. The returned
+// map must not be modified.
+func LabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return labels
+}
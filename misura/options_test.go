@@ -0,0 +1,47 @@
+package misura
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions("Store")
+	assert.Equal(t, "Store", o.Name)
+	assert.Nil(t, o.Recorder)
+	assert.Equal(t, SystemClock{}, o.Clock)
+	assert.NotNil(t, o.Sampler)
+	assert.NotNil(t, o.Classifier)
+	assert.Nil(t, o.Labels)
+
+	clock := &testClock{}
+	o = NewOptions("Store",
+		WithName("users"),
+		WithClock(clock),
+		WithLabels(map[string]string{"region": "eu", "tenant": "a"}),
+		WithLabels(map[string]string{"tenant": "b"}),
+	)
+	assert.Equal(t, "users", o.Name)
+	assert.Equal(t, clock, o.Clock)
+	assert.Equal(t, map[string]string{"region": "eu", "tenant": "b"}, o.Labels)
+}
+
+func TestContextWithLabels(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, LabelsFromContext(ctx))
+	assert.Equal(t, ctx, ContextWithLabels(ctx, nil))
+
+	ctx = ContextWithLabels(ctx, map[string]string{"region": "eu"})
+	assert.Equal(t, map[string]string{"region": "eu"}, LabelsFromContext(ctx))
+}
+
+func TestCloneLabels(t *testing.T) {
+	labels := map[string]string{"region": "eu"}
+	clone := CloneLabels(labels, 1)
+	clone["tenant"] = "a"
+	assert.Equal(t, map[string]string{"region": "eu"}, labels)
+	assert.Equal(t, map[string]string{"region": "eu", "tenant": "a"}, clone)
+	assert.Empty(t, CloneLabels(nil, 1))
+}
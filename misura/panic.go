@@ -0,0 +1,33 @@
+package misura
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned by wrappers generated with -recover
+// when the wrapped method panics.
+type PanicError struct {
+	// Value is the recovered value.
+	Value any
+	Stack []byte
+}
+
+// NewPanicError creates a PanicError for the recovered value v
+// capturing the current stack.
+func NewPanicError(v any) *PanicError {
+	return &PanicError{
+		Value: v,
+		Stack: debug.Stack(),
+	}
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("misura: recovered from panic: %v", p.Value)
+}
+
+// Unwrap returns the recovered value if it is an error.
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
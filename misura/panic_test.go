@@ -0,0 +1,20 @@
+package misura
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanicError(t *testing.T) {
+	err := NewPanicError("boom")
+	assert.Equal(t, "misura: recovered from panic: boom", err.Error())
+	assert.Nil(t, err.Unwrap())
+	assert.NotEmpty(t, err.Stack)
+
+	cause := errors.New("cause")
+	var pe *PanicError
+	assert.ErrorIs(t, NewPanicError(cause), cause)
+	assert.ErrorAs(t, error(NewPanicError(cause)), &pe)
+}
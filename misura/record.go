@@ -0,0 +1,296 @@
+package misura
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// RecordFormat is the encoding of recorded calls.
+type RecordFormat int
+
+const (
+	// RecordJSONL writes each call as a line of JSON.
+	RecordJSONL RecordFormat = iota
+	// RecordGob writes calls as a gob stream.
+	RecordGob
+)
+
+// CallRecord is a recorded call. Params and Results hold the encoded
+// parameters, excluding context.Context, and results, excluding the error.
+type CallRecord struct {
+	Intr    string
+	Method  string
+	Params  json.RawMessage `json:",omitempty"`
+	Results json.RawMessage `json:",omitempty"`
+	Err     string          `json:",omitempty"`
+}
+
+// RecordedError is returned by replayed calls that failed when recorded.
+// Only the message of the original error is kept.
+type RecordedError struct {
+	Message string
+}
+
+func (e RecordedError) Error() string {
+	return e.Message
+}
+
+// ErrNoRecording is returned by CallReplayer when there are
+// no recorded calls left that match a call.
+type ErrNoRecording struct {
+	Intr   string
+	Method string
+}
+
+func (e ErrNoRecording) Error() string {
+	return fmt.Sprintf("misura: no recording left for %s.%s", e.Intr, e.Method)
+}
+
+func encodeValue(format RecordFormat, v any) ([]byte, error) {
+	// nothing to encode, gob can't encode structs without fields
+	if v == nil || reflect.TypeOf(v).Kind() == reflect.Struct && reflect.TypeOf(v).NumField() == 0 {
+		return nil, nil
+	}
+
+	if format == RecordGob {
+		b := &bytes.Buffer{}
+		if err := gob.NewEncoder(b).Encode(v); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	return json.Marshal(v)
+}
+
+func decodeValue(format RecordFormat, data []byte, v any) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if format == RecordGob {
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// CallRecorder writes calls of generated record wrappers to an io.Writer.
+// It's safe for concurrent use.
+type CallRecorder struct {
+	format RecordFormat
+
+	mu  sync.Mutex
+	w   io.Writer
+	gob *gob.Encoder
+	err error
+}
+
+// NewCallRecorder creates a CallRecorder writing to w using format.
+func NewCallRecorder(w io.Writer, format RecordFormat) *CallRecorder {
+	r := &CallRecorder{format: format, w: w}
+	if format == RecordGob {
+		r.gob = gob.NewEncoder(w)
+	}
+
+	return r
+}
+
+// Record writes a call. params and results must be encodable using the
+// format of r. Errors are not returned to keep recording transparent to
+// callers, use Err to check them. Calls to a nil *CallRecorder are ignored.
+func (r *CallRecorder) Record(intr, method string, params, results any, callErr error) {
+	if r == nil {
+		return
+	}
+
+	rec := CallRecord{Intr: intr, Method: method}
+	if callErr != nil {
+		rec.Err = callErr.Error()
+	}
+
+	var err error
+	if rec.Params, err = encodeValue(r.format, params); err == nil {
+		rec.Results, err = encodeValue(r.format, results)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil {
+		err = r.write(rec)
+	}
+
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("misura: failed to record %s.%s: %w", intr, method, err)
+	}
+}
+
+func (r *CallRecorder) write(rec CallRecord) error {
+	if r.format == RecordGob {
+		return r.gob.Encode(rec)
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.w.Write(append(b, '\n'))
+	return err
+}
+
+// Err returns the first error that happened while recording.
+func (r *CallRecorder) Err() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// ReplayMode decides which recorded call is used to replay a call.
+type ReplayMode int
+
+const (
+	// ReplayInOrder replays recorded calls of each method in order.
+	ReplayInOrder ReplayMode = iota
+	// ReplayMatchParams replays the first unused recorded call of the
+	// method with the same parameters. JSONL parameters are compared in
+	// their encoded form. Gob parameters are decoded and compared using
+	// reflect.DeepEqual, since gob encodings depend on the order types
+	// were first encoded in the process.
+	ReplayMatchParams
+)
+
+// CallReplayer serves calls recorded by CallRecorder to generated
+// replay implementations. It's safe for concurrent use.
+type CallReplayer struct {
+	format RecordFormat
+	mode   ReplayMode
+
+	mu      sync.Mutex
+	records []CallRecord
+	used    []bool
+}
+
+// NewCallReplayer reads all recorded calls from r.
+func NewCallReplayer(r io.Reader, format RecordFormat, mode ReplayMode) (*CallReplayer, error) {
+	var records []CallRecord
+	if format == RecordGob {
+		dec := gob.NewDecoder(r)
+		for {
+			var rec CallRecord
+			if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			records = append(records, rec)
+		}
+	} else {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(nil, 64*1024*1024)
+		for sc.Scan() {
+			if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+				continue
+			}
+
+			var rec CallRecord
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				return nil, err
+			}
+			records = append(records, rec)
+		}
+
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &CallReplayer{
+		format:  format,
+		mode:    mode,
+		records: records,
+		used:    make([]bool, len(records)),
+	}, nil
+}
+
+// Replay finds a recorded call and decodes its results into results, which
+// must be a pointer. callErr is the recorded error of the call, while err
+// is set if no recorded call was found or results can't be decoded.
+func (r *CallReplayer) Replay(intr, method string, params, results any) (callErr error, err error) {
+	var (
+		encoded []byte
+		decoded any
+	)
+	if r.mode == ReplayMatchParams {
+		if encoded, err = encodeValue(r.format, params); err != nil {
+			return nil, err
+		}
+
+		// decode params to compare them the same way recorded params are
+		if r.format == RecordGob && len(encoded) != 0 {
+			if decoded, err = decodeAs(encoded, reflect.TypeOf(params)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, rec := range r.records {
+		if r.used[i] || rec.Intr != intr || rec.Method != method {
+			continue
+		}
+
+		if r.mode == ReplayMatchParams && !r.matchParams(rec.Params, encoded, decoded) {
+			continue
+		}
+
+		r.used[i] = true
+		if err := decodeValue(r.format, rec.Results, results); err != nil {
+			return nil, err
+		}
+
+		if rec.Err != "" {
+			return RecordedError{Message: rec.Err}, nil
+		}
+
+		return nil, nil
+	}
+
+	return nil, ErrNoRecording{Intr: intr, Method: method}
+}
+
+// matchParams reports whether recorded params are equal to params, which
+// are passed both encoded and, for gob, decoded.
+func (r *CallReplayer) matchParams(recorded, encoded []byte, decoded any) bool {
+	if decoded == nil || len(recorded) == 0 {
+		return bytes.Equal(recorded, encoded)
+	}
+
+	v, err := decodeAs(recorded, reflect.TypeOf(decoded))
+	return err == nil && reflect.DeepEqual(v, decoded)
+}
+
+// decodeAs decodes gob encoded data into a new value of type t.
+func decodeAs(data []byte, t reflect.Type) (any, error) {
+	v := reflect.New(t)
+	if err := decodeValue(RecordGob, data, v.Interface()); err != nil {
+		return nil, err
+	}
+
+	return v.Elem().Interface(), nil
+}
@@ -0,0 +1,113 @@
+package misura
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordParams struct {
+	ID string
+}
+
+type recordResults struct {
+	Name string
+}
+
+func TestRecordReplay(t *testing.T) {
+	for name, format := range map[string]RecordFormat{"jsonl": RecordJSONL, "gob": RecordGob} {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			r := NewCallRecorder(buf, format)
+			r.Record("Store", "Get", recordParams{ID: "1"}, recordResults{Name: "one"}, nil)
+			r.Record("Store", "Get", recordParams{ID: "2"}, recordResults{}, errors.New("not found"))
+			r.Record("Store", "Len", struct{}{}, nil, nil)
+			require.NoError(t, r.Err())
+
+			t.Run("in_order", func(t *testing.T) {
+				replayer, err := NewCallReplayer(bytes.NewReader(buf.Bytes()), format, ReplayInOrder)
+				require.NoError(t, err)
+
+				var results recordResults
+				callErr, err := replayer.Replay("Store", "Get", recordParams{ID: "2"}, &results)
+				require.NoError(t, err)
+				require.NoError(t, callErr)
+				assert.Equal(t, "one", results.Name)
+
+				callErr, err = replayer.Replay("Store", "Get", recordParams{ID: "1"}, &recordResults{})
+				require.NoError(t, err)
+				assert.Equal(t, RecordedError{Message: "not found"}, callErr)
+
+				_, err = replayer.Replay("Store", "Get", recordParams{ID: "1"}, &recordResults{})
+				require.ErrorAs(t, err, &ErrNoRecording{})
+
+				_, err = replayer.Replay("Store", "Len", struct{}{}, nil)
+				require.NoError(t, err)
+			})
+
+			t.Run("match_params", func(t *testing.T) {
+				replayer, err := NewCallReplayer(bytes.NewReader(buf.Bytes()), format, ReplayMatchParams)
+				require.NoError(t, err)
+
+				callErr, err := replayer.Replay("Store", "Get", recordParams{ID: "2"}, &recordResults{})
+				require.NoError(t, err)
+				assert.Error(t, callErr)
+
+				var results recordResults
+				callErr, err = replayer.Replay("Store", "Get", recordParams{ID: "1"}, &results)
+				require.NoError(t, err)
+				require.NoError(t, callErr)
+				assert.Equal(t, "one", results.Name)
+			})
+		})
+	}
+}
+
+// replayGetParams and replayFindParams have the same fields as the
+// recorded params, but are encoded for the first time when replaying.
+// Like in a new process, gob assigns them new type ids.
+type (
+	recordFindParams struct {
+		Query string
+		Tags  map[string]string
+	}
+	replayGetParams struct {
+		ID string
+	}
+	replayFindParams struct {
+		Query string
+		Tags  map[string]string
+	}
+)
+
+func TestReplayMatchParamsGobTypes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := NewCallRecorder(buf, RecordGob)
+	r.Record("Store", "Get", recordParams{ID: "1"}, recordResults{Name: "one"}, nil)
+	r.Record("Store", "Find", recordFindParams{Query: "q", Tags: map[string]string{"a": "1", "b": "2", "c": "3"}}, recordResults{Name: "found"}, nil)
+	require.NoError(t, r.Err())
+
+	replayer, err := NewCallReplayer(bytes.NewReader(buf.Bytes()), RecordGob, ReplayMatchParams)
+	require.NoError(t, err)
+
+	var results recordResults
+	_, err = replayer.Replay("Store", "Find", replayFindParams{Query: "q", Tags: map[string]string{"c": "3", "b": "2", "a": "1"}}, &results)
+	require.NoError(t, err)
+	assert.Equal(t, "found", results.Name)
+
+	_, err = replayer.Replay("Store", "Get", replayGetParams{ID: "1"}, &results)
+	require.NoError(t, err)
+	assert.Equal(t, "one", results.Name)
+
+	_, err = replayer.Replay("Store", "Get", replayGetParams{ID: "1"}, &results)
+	require.ErrorAs(t, err, &ErrNoRecording{})
+}
+
+func TestNilCallRecorder(t *testing.T) {
+	var r *CallRecorder
+	r.Record("Store", "Get", recordParams{ID: "1"}, recordResults{Name: "one"}, nil)
+	assert.NoError(t, r.Err())
+}
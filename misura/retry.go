@@ -0,0 +1,114 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures how generated retry wrappers retry a method.
+// Zero values are replaced with defaults.
+type RetryPolicy struct {
+	// MaxAttempts including the first call. Defaults to 3.
+	MaxAttempts int
+
+	// InitialBackoff is the wait time before the second attempt.
+	// Defaults to 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait time between attempts. Defaults to 10s.
+	MaxBackoff time.Duration
+
+	// Multiplier is applied to the backoff after each attempt.
+	// Defaults to 2.
+	Multiplier float64
+
+	// Jitter is the fraction of the backoff that will be randomized,
+	// i.e. 0.2 means ±20%. Must be in [0, 1].
+	Jitter float64
+
+	// Retryable decides if an error should be retried. By default
+	// all errors except context cancellation are retried.
+	Retryable func(err error) bool
+
+	// Clock is used to time attempts and wait between them.
+	// Defaults to SystemClock.
+	Clock Clock
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 10 * time.Second
+	}
+
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+
+	p.Jitter = math.Max(0, math.Min(p.Jitter, 1))
+
+	if p.Retryable == nil {
+		p.Retryable = defaultRetryable
+	}
+
+	if p.Clock == nil {
+		p.Clock = SystemClock{}
+	}
+
+	return p
+}
+
+func defaultRetryable(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// Backoff returns the wait time after the given attempt, starting from 1.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	p = p.withDefaults()
+
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	backoff = math.Min(backoff, float64(p.MaxBackoff))
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(backoff)
+}
+
+// Retry calls fn until it succeeds, the error is not retryable or MaxAttempts
+// is reached. report, if not nil, is called after each attempt. Waiting between
+// attempts stops as soon as ctx is done, in which case the last error joined
+// with ctx.Err() is returned.
+func Retry(ctx context.Context, policy RetryPolicy, fn func() error, report func(attempt int, duration time.Duration, err error)) error {
+	policy = policy.withDefaults()
+
+	for attempt := 1; ; attempt++ {
+		start := policy.Clock.Now()
+		err := fn()
+		if report != nil {
+			report(attempt, policy.Clock.Now().Sub(start), err)
+		}
+
+		if err == nil || attempt >= policy.MaxAttempts || !policy.Retryable(err) {
+			return err
+		}
+
+		timer := newTimer(policy.Clock, policy.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C():
+		}
+	}
+}
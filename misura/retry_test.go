@@ -0,0 +1,89 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	errTemporary := errors.New("temporary")
+	policy := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond}
+
+	t.Run("succeeds_after_retries", func(t *testing.T) {
+		var attempts []int
+		calls := 0
+		err := Retry(context.Background(), policy, func() error {
+			calls++
+			if calls < 3 {
+				return errTemporary
+			}
+			return nil
+		}, func(attempt int, _ time.Duration, _ error) {
+			attempts = append(attempts, attempt)
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3}, attempts)
+	})
+
+	t.Run("max_attempts", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), policy, func() error {
+			calls++
+			return errTemporary
+		}, nil)
+
+		require.ErrorIs(t, err, errTemporary)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("not_retryable", func(t *testing.T) {
+		calls := 0
+		p := policy
+		p.Retryable = func(err error) bool { return !errors.Is(err, errTemporary) }
+		err := Retry(context.Background(), p, func() error {
+			calls++
+			return errTemporary
+		}, nil)
+
+		require.ErrorIs(t, err, errTemporary)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("ctx_canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := policy
+		p.InitialBackoff = time.Hour
+
+		calls := 0
+		err := Retry(ctx, p, func() error {
+			calls++
+			cancel()
+			return errTemporary
+		}, nil)
+
+		require.ErrorIs(t, err, errTemporary)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 20*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 40*time.Millisecond, p.Backoff(3))
+	assert.Equal(t, 50*time.Millisecond, p.Backoff(4))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		b := p.Backoff(1)
+		assert.GreaterOrEqual(t, b, 5*time.Millisecond)
+		assert.LessOrEqual(t, b, 15*time.Millisecond)
+	}
+}
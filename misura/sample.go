@@ -0,0 +1,91 @@
+package misura
+
+import (
+	"context"
+	"math/rand"
+)
+
+// Sampler decides which calls are recorded by metrics wrappers generated
+// with -sample. Calls that are not sampled are passed to the wrapped
+// method without calling time.Now or metrics.
+type Sampler interface {
+	// Sample reports whether a call to method should be recorded and
+	// the rate it's sampled with, in (0, 1].
+	Sample(method string) (rate float64, sampled bool)
+}
+
+// SamplerFunc is an adapter to use ordinary functions as Sampler.
+type SamplerFunc func(method string) (rate float64, sampled bool)
+
+func (f SamplerFunc) Sample(method string) (float64, bool) {
+	return f(method)
+}
+
+// AlwaysSample returns a Sampler that records every call.
+func AlwaysSample() Sampler {
+	return SamplerFunc(func(string) (float64, bool) {
+		return 1, true
+	})
+}
+
+// RateSampler returns a Sampler that records calls with probability rate.
+// Rates above 1 record every call and rates below or equal to 0 record none.
+func RateSampler(rate float64) Sampler {
+	if rate >= 1 {
+		return AlwaysSample()
+	}
+
+	return SamplerFunc(func(string) (float64, bool) {
+		return rate, rate > 0 && rand.Float64() < rate
+	})
+}
+
+// MethodSampler uses the Sampler of each method, or Default
+// for methods that don't have one.
+type MethodSampler struct {
+	// Default defaults to AlwaysSample.
+	Default Sampler
+
+	Methods map[string]Sampler
+}
+
+func (s MethodSampler) Sample(method string) (float64, bool) {
+	if sampler, ok := s.Methods[method]; ok {
+		return sampler.Sample(method)
+	}
+
+	if s.Default == nil {
+		return 1, true
+	}
+
+	return s.Default.Sample(method)
+}
+
+type sampleRateKey struct{}
+
+// WithSampleRate returns a copy of ctx carrying rate. Generated wrappers
+// use this to pass the rate of sampled calls to metrics. ctx is returned
+// as is if every call is sampled.
+func WithSampleRate(ctx context.Context, rate float64) context.Context {
+	if rate >= 1 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, sampleRateKey{}, rate)
+}
+
+// SampleRate returns the rate the call was sampled with, or 1 if it's not
+// stored in ctx.
+func SampleRate(ctx context.Context) float64 {
+	if rate, ok := ctx.Value(sampleRateKey{}).(float64); ok {
+		return rate
+	}
+
+	return 1
+}
+
+// SampleWeight returns the number of calls the sampled call represents.
+// Backends should add it to counters instead of 1 to scale counts.
+func SampleWeight(ctx context.Context) float64 {
+	return 1 / SampleRate(ctx)
+}
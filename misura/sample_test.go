@@ -0,0 +1,53 @@
+package misura
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateSampler(t *testing.T) {
+	rate, sampled := RateSampler(2).Sample("Get")
+	assert.True(t, sampled)
+	assert.Equal(t, 1.0, rate)
+
+	_, sampled = RateSampler(0).Sample("Get")
+	assert.False(t, sampled)
+
+	s := RateSampler(0.25)
+	n := 0
+	for i := 0; i < 10000; i++ {
+		rate, sampled := s.Sample("Get")
+		assert.Equal(t, 0.25, rate)
+		if sampled {
+			n++
+		}
+	}
+	assert.InDelta(t, 2500, n, 300)
+}
+
+func TestMethodSampler(t *testing.T) {
+	s := MethodSampler{Methods: map[string]Sampler{"Get": RateSampler(0)}}
+
+	_, sampled := s.Sample("Get")
+	assert.False(t, sampled)
+
+	rate, sampled := s.Sample("List")
+	assert.True(t, sampled, "defaults to AlwaysSample")
+	assert.Equal(t, 1.0, rate)
+
+	s.Default = RateSampler(0)
+	_, sampled = s.Sample("List")
+	assert.False(t, sampled)
+}
+
+func TestSampleRate(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, 1.0, SampleRate(ctx))
+	assert.Equal(t, ctx, WithSampleRate(ctx, 1))
+
+	ctx = WithSampleRate(ctx, 0.1)
+	assert.Equal(t, 0.1, SampleRate(ctx))
+	assert.InDelta(t, 10, SampleWeight(ctx), 1e-9)
+}
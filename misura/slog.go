@@ -0,0 +1,175 @@
+package misura
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// SlogOptions configures SlogRecorder.
+type SlogOptions struct {
+	// SuccessLevel is used to log successful calls.
+	// Defaults to slog.LevelDebug.
+	SuccessLevel slog.Leveler
+
+	// FailureLevel is used to log failed calls.
+	// Defaults to slog.LevelError.
+	FailureLevel slog.Leveler
+
+	// MissLevel is used to log calls reported as a miss by
+	// the miss measure. Defaults to slog.LevelInfo.
+	MissLevel slog.Leveler
+
+	// TotalLevel is used to log the start of every call.
+	// If nil, start of the calls will not be logged.
+	TotalLevel slog.Leveler
+
+	// LogValues, if set to true, will log parameters and results
+	// of the call. This requires the wrapper to be generated with
+	// -capture. Redacted values will be logged as RedactedValue.
+	LogValues bool
+}
+
+// SlogRecorder logs each call of a generated wrapper through a *slog.Logger.
+// It can be passed as metrics to any wrapper generated with the duration
+// measure. In-flight calls are not logged, they are tracked by the embedded
+// InflightGauge instead.
+type SlogRecorder struct {
+	*InflightGauge
+
+	logger *slog.Logger
+	opts   SlogOptions
+}
+
+// NewSlogRecorder creates a SlogRecorder. If logger is nil slog.Default() will be used.
+func NewSlogRecorder(logger *slog.Logger, opts SlogOptions) *SlogRecorder {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	if opts.SuccessLevel == nil {
+		opts.SuccessLevel = slog.LevelDebug
+	}
+
+	if opts.FailureLevel == nil {
+		opts.FailureLevel = slog.LevelError
+	}
+
+	if opts.MissLevel == nil {
+		opts.MissLevel = slog.LevelInfo
+	}
+
+	return &SlogRecorder{
+		InflightGauge: NewInflightGauge(),
+		logger:        logger,
+		opts:          opts,
+	}
+}
+
+func (s *SlogRecorder) Failure(ctx context.Context, name, pkg, intr, method string, duration time.Duration, err error) {
+	s.log(ctx, s.opts.FailureLevel, "call failed", name, pkg, intr, method,
+		slog.Duration("duration", duration),
+		slog.Any("error", err),
+	)
+}
+
+// ClassifiedSlogRecorder is a SlogRecorder for wrappers generated with
+// -classify. Failure logs the class of the error alongside it.
+type ClassifiedSlogRecorder struct {
+	*SlogRecorder
+}
+
+// NewClassifiedSlogRecorder creates a ClassifiedSlogRecorder. See NewSlogRecorder.
+func NewClassifiedSlogRecorder(logger *slog.Logger, opts SlogOptions) *ClassifiedSlogRecorder {
+	return &ClassifiedSlogRecorder{SlogRecorder: NewSlogRecorder(logger, opts)}
+}
+
+func (s *ClassifiedSlogRecorder) Failure(ctx context.Context, name, pkg, intr, method string, duration time.Duration, class string, err error) {
+	s.log(ctx, s.opts.FailureLevel, "call failed", name, pkg, intr, method,
+		slog.Duration("duration", duration),
+		slog.String("class", class),
+		slog.Any("error", err),
+	)
+}
+
+func (s *SlogRecorder) Miss(ctx context.Context, name, pkg, intr, method string, duration time.Duration) {
+	s.log(ctx, s.opts.MissLevel, "call missed", name, pkg, intr, method,
+		slog.Duration("duration", duration),
+	)
+}
+
+func (s *SlogRecorder) Panic(ctx context.Context, name, pkg, intr, method string, duration time.Duration, recovered any) {
+	s.log(ctx, s.opts.FailureLevel, "call panicked", name, pkg, intr, method,
+		slog.Duration("duration", duration),
+		slog.Any("panic", recovered),
+	)
+}
+
+func (s *SlogRecorder) Success(ctx context.Context, name, pkg, intr, method string, duration time.Duration) {
+	s.log(ctx, s.opts.SuccessLevel, "call succeeded", name, pkg, intr, method,
+		slog.Duration("duration", duration),
+	)
+}
+
+func (s *SlogRecorder) Total(ctx context.Context, name, pkg, intr, method string) {
+	if s.opts.TotalLevel == nil {
+		return
+	}
+
+	s.log(ctx, s.opts.TotalLevel, "call started", name, pkg, intr, method)
+}
+
+func (s *SlogRecorder) log(ctx context.Context, level slog.Leveler, msg, name, pkg, intr, method string, attrs ...slog.Attr) {
+	if !s.logger.Enabled(ctx, level.Level()) {
+		return
+	}
+
+	attrs = append([]slog.Attr{
+		slog.String("name", name),
+		slog.String("pkg", pkg),
+		slog.String("intr", intr),
+		slog.String("method", method),
+	}, attrs...)
+
+	if rate := SampleRate(ctx); rate < 1 {
+		attrs = append(attrs, slog.Float64("sample_rate", rate))
+	}
+
+	if labels := LabelsFromContext(ctx); len(labels) != 0 {
+		attrs = append(attrs, slog.Attr{Key: "labels", Value: labelsValue(labels)})
+	}
+
+	if info, ok := CallInfoFromContext(ctx); ok && s.opts.LogValues {
+		attrs = append(attrs,
+			slog.Attr{Key: "params", Value: fieldsValue(info.Params)},
+			slog.Attr{Key: "results", Value: fieldsValue(info.Results)},
+		)
+	}
+
+	s.logger.LogAttrs(ctx, level.Level(), msg, attrs...)
+}
+
+func labelsValue(labels map[string]string) slog.Value {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(labels))
+	for _, k := range keys {
+		attrs = append(attrs, slog.String(k, labels[k]))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+func fieldsValue(fields []Field) slog.Value {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.Name, f.Value))
+	}
+
+	return slog.GroupValue(attrs...)
+}
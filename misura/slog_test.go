@@ -0,0 +1,139 @@
+package misura
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogRecorder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := NewSlogRecorder(logger, SlogOptions{})
+
+	r.Total(context.Background(), "n", "pkg", "Intr", "Get")
+	require.Zero(t, buf.Len(), "total should not be logged by default")
+
+	r.Success(context.Background(), "n", "pkg", "Intr", "Get", time.Second)
+	line := decodeLine(t, buf)
+	assert.Equal(t, "DEBUG", line["level"])
+	assert.Equal(t, "call succeeded", line["msg"])
+	assert.Equal(t, "Get", line["method"])
+	assert.EqualValues(t, time.Second, line["duration"])
+
+	r.Failure(context.Background(), "n", "pkg", "Intr", "Get", time.Millisecond, errors.New("boom"))
+	line = decodeLine(t, buf)
+	assert.Equal(t, "ERROR", line["level"])
+	assert.Equal(t, "call failed", line["msg"])
+	assert.Equal(t, "boom", line["error"])
+
+	r.Miss(context.Background(), "n", "pkg", "Intr", "Get", time.Millisecond)
+	line = decodeLine(t, buf)
+	assert.Equal(t, "INFO", line["level"])
+	assert.Equal(t, "call missed", line["msg"])
+
+	r.Inflight(context.Background(), "n", "pkg", "Intr", "Get", 1)
+	require.Zero(t, buf.Len(), "in-flight calls should not be logged")
+	assert.EqualValues(t, 1, r.Value("n", "pkg", "Intr", "Get"))
+}
+
+func TestSlogRecorderLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	r := NewSlogRecorder(logger, SlogOptions{
+		FailureLevel: slog.LevelWarn,
+		TotalLevel:   slog.LevelInfo,
+	})
+
+	r.Success(context.Background(), "n", "pkg", "Intr", "Get", time.Second)
+	require.Zero(t, buf.Len(), "success is logged with debug level which is disabled")
+
+	r.Total(context.Background(), "n", "pkg", "Intr", "Get")
+	line := decodeLine(t, buf)
+	assert.Equal(t, "INFO", line["level"])
+	assert.Equal(t, "call started", line["msg"])
+
+	r.Failure(context.Background(), "n", "pkg", "Intr", "Get", time.Second, errors.New("boom"))
+	line = decodeLine(t, buf)
+	assert.Equal(t, "WARN", line["level"])
+}
+
+func TestClassifiedSlogRecorder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := NewClassifiedSlogRecorder(logger, SlogOptions{})
+
+	r.Failure(context.Background(), "n", "pkg", "Intr", "Get", time.Second, ClassTimeout, errors.New("boom"))
+	line := decodeLine(t, buf)
+	assert.Equal(t, "ERROR", line["level"])
+	assert.Equal(t, "timeout", line["class"])
+	assert.Equal(t, "boom", line["error"])
+
+	r.Success(context.Background(), "n", "pkg", "Intr", "Get", time.Second)
+	assert.Equal(t, "call succeeded", decodeLine(t, buf)["msg"])
+}
+
+func TestSlogRecorderSampleRate(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := NewSlogRecorder(logger, SlogOptions{})
+
+	r.Success(context.Background(), "n", "pkg", "Intr", "Get", time.Second)
+	assert.NotContains(t, decodeLine(t, buf), "sample_rate")
+
+	r.Success(WithSampleRate(context.Background(), 0.5), "n", "pkg", "Intr", "Get", time.Second)
+	assert.Equal(t, 0.5, decodeLine(t, buf)["sample_rate"])
+}
+
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+
+	line, err := buf.ReadBytes('\n')
+	require.NoError(t, err)
+
+	m := map[string]any{}
+	require.NoError(t, json.Unmarshal(line, &m))
+	return m
+}
+
+func TestSlogRecorderLogValues(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := NewSlogRecorder(logger, SlogOptions{LogValues: true})
+
+	ctx := WithCallInfo(context.Background(), CallInfo{
+		Params:  []Field{Capture("id", "42"), Redact("password")},
+		Results: []Field{Capture("name", secret("sina"))},
+	})
+
+	r.Success(ctx, "n", "pkg", "Intr", "Get", time.Second)
+	line := decodeLine(t, buf)
+	assert.Equal(t, map[string]any{"id": "42", "password": RedactedValue}, line["params"])
+	assert.Equal(t, map[string]any{"name": "s***"}, line["results"])
+}
+
+type secret string
+
+func (s secret) Redacted() any {
+	return string(s[0]) + "***"
+}
+
+func TestSlogRecorderLabels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := NewSlogRecorder(logger, SlogOptions{})
+
+	r.Success(context.Background(), "n", "pkg", "Intr", "Get", time.Second)
+	assert.NotContains(t, decodeLine(t, buf), "labels")
+
+	ctx := ContextWithLabels(context.Background(), map[string]string{"region": "eu", "tenant": "a"})
+	r.Success(ctx, "n", "pkg", "Intr", "Get", time.Second)
+	assert.Equal(t, map[string]any{"region": "eu", "tenant": "a"}, decodeLine(t, buf)["labels"])
+}
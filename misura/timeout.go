@@ -0,0 +1,58 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeoutPolicy configures deadlines of generated timeout wrappers.
+type TimeoutPolicy struct {
+	// Default is used for methods without //misura:timeout.
+	// Zero means no deadline.
+	Default time.Duration
+
+	// Methods overrides the timeout of methods by name,
+	// including annotated ones. Zero means no deadline.
+	Methods map[string]time.Duration
+
+	// Clock decides when deadlines are exceeded, see WithTimeout.
+	// Defaults to SystemClock.
+	Clock Clock
+}
+
+// Timeout returns the timeout of method. annotated is the value of
+// //misura:timeout or zero if the method is not annotated.
+func (p TimeoutPolicy) Timeout(method string, annotated time.Duration) time.Duration {
+	if timeout, ok := p.Methods[method]; ok {
+		return timeout
+	}
+
+	if annotated > 0 {
+		return annotated
+	}
+
+	return p.Default
+}
+
+// TimeoutError is returned by generated timeout wrappers when the
+// deadline they derived is exceeded.
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("misura: timed out after %s: %v", e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// TimedOut reports whether ctx, derived from parent, exceeded its own
+// deadline. It's false if parent is done as well.
+func TimedOut(parent, ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+}
@@ -0,0 +1,43 @@
+package misura
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutPolicy(t *testing.T) {
+	p := TimeoutPolicy{
+		Default: time.Second,
+		Methods: map[string]time.Duration{"Get": time.Minute, "List": 0},
+	}
+
+	assert.Equal(t, time.Minute, p.Timeout("Get", 2*time.Second), "config overrides annotations")
+	assert.Zero(t, p.Timeout("List", 2*time.Second))
+	assert.Equal(t, 2*time.Second, p.Timeout("Put", 2*time.Second))
+	assert.Equal(t, time.Second, p.Timeout("Put", 0))
+}
+
+func TestTimedOut(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	defer cancelParent()
+
+	ctx, cancel := context.WithTimeout(parent, time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	assert.True(t, TimedOut(parent, ctx))
+
+	cancelParent()
+	assert.False(t, TimedOut(parent, ctx), "parent is done as well")
+}
+
+func TestTimeoutError(t *testing.T) {
+	err := error(&TimeoutError{Timeout: time.Second, Err: context.DeadlineExceeded})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, ClassTimeout, DefaultErrorClassifier().Classify(err))
+	assert.Equal(t, ClassDeadlineExceeded, DefaultErrorClassifier().Classify(errors.Join(context.DeadlineExceeded)))
+}
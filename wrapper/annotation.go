@@ -0,0 +1,595 @@
+package wrapper
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itzloop/misura/wrapper/types"
+)
+
+const annotationPrefix = "//misura:"
+
+// knownAnnotations are keys that can be used with //misura:<key>=<value>
+// on methods or types. Anything else is considered a target.
+var knownAnnotations = types.Strings{
+	"redact",
+	"ok",
+	"retry",
+	"timeout",
+	"cache",
+	"cachekey",
+	"hedge",
+	"label",
+}
+
+// parseAnnotation parses //misura:<key>[=<value>].
+func parseAnnotation(text string) (types.Annotation, bool) {
+	if !strings.HasPrefix(text, annotationPrefix) {
+		return types.Annotation{}, false
+	}
+
+	key, value, _ := strings.Cut(strings.TrimPrefix(text, annotationPrefix), "=")
+	return types.Annotation{
+		Key:   strings.TrimSpace(key),
+		Value: strings.TrimSpace(value),
+	}, true
+}
+
+// isTarget reports whether a //misura: comment specifies a target
+// rather than an annotation.
+func isTarget(an types.Annotation) bool {
+	return an.Value == "" && !knownAnnotations.Exists(an.Key)
+}
+
+func parseAnnotations(docs ...*ast.CommentGroup) types.Annotations {
+	var annotations types.Annotations
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+
+		for _, c := range doc.List {
+			an, ok := parseAnnotation(c.Text)
+			if !ok || isTarget(an) {
+				continue
+			}
+
+			annotations = append(annotations, an)
+		}
+	}
+
+	return annotations
+}
+
+// handleRedaction marks parameters and results listed in //misura:redact
+// either by name or by type.
+func handleRedaction(m *types.Method) {
+	redact := m.Annotations.Values("redact")
+	if len(redact) == 0 {
+		return
+	}
+
+	for i, p := range m.Params {
+		m.Params[i].Redact = redact.Exists(p.Name) || redact.Exists(p.Type)
+	}
+
+	for i, r := range m.Results {
+		m.Results[i].Redact = redact.Exists(r.Name) || redact.Exists(r.Type)
+	}
+}
+
+// handleOK resolves //misura:ok which specifies the result that decides if
+// the call failed. Possible values are:
+//  1. empty: last result, ignoring errors, which must be a bool.
+//  2. .Field: field of the last result, ignoring errors (i.e. a status struct).
+//  3. name[.Field]: a named result or its field.
+//
+// Fields are resolved using structs declared in the package and must be
+// bools. Type annotations are only applied to methods they can be resolved
+// for, while method annotations must be valid.
+func handleOK(m *types.Method, typeAnnotations, methodAnnotations types.Annotations, decls map[string]ast.Expr) error {
+	return applyAnnotation("ok", typeAnnotations, methodAnnotations, func(value string) error {
+		return resolveOK(m, value, decls)
+	})
+}
+
+// handleRetry resolves //misura:retry[=<max attempts>] which opts methods
+// into retries when the retry wrapper is generated. Only methods whose last
+// result is an error can be retried.
+func handleRetry(m *types.Method, typeAnnotations, methodAnnotations types.Annotations) error {
+	return applyAnnotation("retry", typeAnnotations, methodAnnotations, func(value string) error {
+		if !m.LastResultIsError() {
+			return notApplicable("//misura:retry requires the last result to be an error")
+		}
+
+		if value != "" {
+			attempts, err := strconv.Atoi(value)
+			if err != nil || attempts < 1 {
+				return fmt.Errorf("//misura:retry=%s: max attempts must be a positive integer", value)
+			}
+
+			m.RetryAttempts = attempts
+		}
+
+		m.Retry = true
+		return nil
+	})
+}
+
+// handleTimeout resolves //misura:timeout=<duration> which sets the deadline
+// of the method when the timeout wrapper is generated. Only methods with a
+// ctx parameter can have a timeout.
+func handleTimeout(m *types.Method, typeAnnotations, methodAnnotations types.Annotations) error {
+	return applyAnnotation("timeout", typeAnnotations, methodAnnotations, func(value string) error {
+		if !m.HasCtx {
+			return notApplicable("//misura:timeout requires a context.Context parameter")
+		}
+
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("//misura:timeout=%s: timeout must be a positive duration", value)
+		}
+
+		m.Timeout = timeout
+		return nil
+	})
+}
+
+// handleHedge resolves //misura:hedge[=<delay>] which fires a second call
+// of the method if the first one hasn't returned after the delay when the
+// hedge wrapper is generated. Only idempotent methods with a ctx parameter,
+// used to cancel the slower call, should be hedged.
+func handleHedge(m *types.Method, typeAnnotations, methodAnnotations types.Annotations) error {
+	return applyAnnotation("hedge", typeAnnotations, methodAnnotations, func(value string) error {
+		if !m.HasCtx {
+			return notApplicable("//misura:hedge requires a context.Context parameter")
+		}
+
+		if value != "" {
+			delay, err := time.ParseDuration(value)
+			if err != nil || delay <= 0 {
+				return fmt.Errorf("//misura:hedge=%s: delay must be a positive duration", value)
+			}
+
+			m.HedgeDelay = delay
+		}
+
+		m.Hedge = true
+		return nil
+	})
+}
+
+// handleCache resolves //misura:cache[=<ttl>] which caches results of the
+// method when the cache wrapper is generated. Parameters, except ctx, are used
+// as the key so they must be comparable. Otherwise //misura:cachekey=<func>
+// must specify a function accepting the same parameters and returning a
+// string key. Types of other packages are resolved using imported.
+func handleCache(m *types.Method, typeAnnotations, methodAnnotations types.Annotations, decls map[string]ast.Expr, imported importedTypes) error {
+	err := applyAnnotation("cache", typeAnnotations, methodAnnotations, func(value string) error {
+		if len(m.Results) == 0 || len(m.Results) == 1 && m.LastResultIsError() {
+			return notApplicable("//misura:cache requires a result to cache")
+		}
+
+		if value != "" {
+			ttl, err := time.ParseDuration(value)
+			if err != nil || ttl <= 0 {
+				return fmt.Errorf("//misura:cache=%s: ttl must be a positive duration", value)
+			}
+
+			m.CacheTTL = ttl
+		}
+
+		key := methodAnnotations.Get("cachekey")
+		if key == "" {
+			for _, p := range m.ParamsWithoutCtx() {
+				if !isComparable(p.Type, decls, imported) {
+					return notApplicable("//misura:cache requires //misura:cachekey since %s is not comparable", p.Name)
+				}
+			}
+		} else if !isFuncName(key) {
+			return fmt.Errorf("//misura:cachekey=%s: invalid function name", key)
+		}
+
+		m.Cache = true
+		m.CacheKey = key
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if methodAnnotations.Has("cachekey") && !m.Cache {
+		return errors.New("//misura:cachekey requires //misura:cache")
+	}
+
+	return nil
+}
+
+// isComparable reports whether values of typ can be used as a map key
+// without panicking. Types declared in the package are resolved using
+// decls and types of other packages using imported. Interfaces are not
+// comparable, since the dynamic value may not be. Types of other packages
+// that can't be resolved are not comparable either.
+func isComparable(typ string, decls map[string]ast.Expr, imported importedTypes) bool {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		// i.e. variadic parameters
+		return false
+	}
+
+	return isComparableExpr(expr, decls, imported, map[string]bool{})
+}
+
+func isComparableExpr(expr ast.Expr, decls map[string]ast.Expr, imported importedTypes, seen map[string]bool) bool {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if x.Name == "any" || x.Name == "error" {
+			return false
+		}
+
+		decl, ok := decls[x.Name]
+		if !ok || seen[x.Name] {
+			return true
+		}
+
+		seen[x.Name] = true
+		return isComparableExpr(decl, decls, imported, seen)
+	case *ast.SelectorExpr:
+		pkg, ok := x.X.(*ast.Ident)
+		if !ok || imported == nil {
+			return false
+		}
+
+		typ := imported(pkg.Name, x.Sel.Name)
+		return typ != nil && isComparableType(typ, map[gotypes.Type]bool{})
+	case *ast.ParenExpr:
+		return isComparableExpr(x.X, decls, imported, seen)
+	case *ast.ArrayType:
+		// slices have no length
+		return x.Len != nil && isComparableExpr(x.Elt, decls, imported, seen)
+	case *ast.StructType:
+		for _, f := range x.Fields.List {
+			if !isComparableExpr(f.Type, decls, imported, seen) {
+				return false
+			}
+		}
+
+		return true
+	case *ast.MapType, *ast.FuncType, *ast.InterfaceType, *ast.Ellipsis:
+		return false
+	}
+
+	// pointers and channels
+	return true
+}
+
+// isComparableType is isComparableExpr for types of other packages.
+func isComparableType(typ gotypes.Type, seen map[gotypes.Type]bool) bool {
+	if seen[typ] {
+		return true
+	}
+	seen[typ] = true
+
+	switch x := typ.Underlying().(type) {
+	case *gotypes.Basic:
+		return x.Kind() != gotypes.UntypedNil
+	case *gotypes.Pointer, *gotypes.Chan:
+		return true
+	case *gotypes.Array:
+		return isComparableType(x.Elem(), seen)
+	case *gotypes.Struct:
+		for i := 0; i < x.NumFields(); i++ {
+			if !isComparableType(x.Field(i).Type(), seen) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	// slices, maps, funcs, interfaces and type parameters
+	return false
+}
+
+// isFuncName reports whether name is a function or pkg.Function.
+func isFuncName(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if !token.IsIdentifier(part) {
+			return false
+		}
+	}
+
+	return len(strings.Split(name, ".")) <= 2
+}
+
+// handleLabels resolves //misura:label=<name>:<param>[.<field>...] which
+// passes the value of a parameter, or a field of it, to metrics as a label.
+// Fields are resolved using structs declared in the package, so a missing
+// field is reported here instead of when compiling the wrapper. Labels should
+// have a low cardinality, i.e. region or tenant but not ids.
+func handleLabels(m *types.Method, typeAnnotations, methodAnnotations types.Annotations, decls map[string]ast.Expr) error {
+	names := types.Strings{}
+	for _, value := range methodAnnotations.Values("label") {
+		label, err := resolveLabel(m, value, decls)
+		if err != nil {
+			return err
+		}
+
+		if names.Exists(label.Name) {
+			return fmt.Errorf("//misura:label=%s: label '%s' is already defined", value, label.Name)
+		}
+
+		names = append(names, label.Name)
+		m.Labels = append(m.Labels, label)
+	}
+
+	for _, value := range typeAnnotations.Values("label") {
+		label, err := resolveLabel(m, value, decls)
+		if err != nil && !errors.Is(err, errNotApplicable) {
+			return err
+		}
+
+		// ignore methods this can't be applied to and labels
+		// overridden by the method
+		if err != nil || names.Exists(label.Name) {
+			continue
+		}
+
+		names = append(names, label.Name)
+		m.Labels = append(m.Labels, label)
+	}
+
+	return nil
+}
+
+func resolveLabel(m *types.Method, value string, decls map[string]ast.Expr) (types.Label, error) {
+	name, expr, _ := strings.Cut(value, ":")
+	path := strings.Split(strings.TrimSpace(expr), ".")
+	for _, part := range append(path, strings.TrimSpace(name)) {
+		if !token.IsIdentifier(part) {
+			return types.Label{}, fmt.Errorf("//misura:label=%s: expected <name>:<param>[.<field>...]", value)
+		}
+	}
+
+	var param *types.FuncParam
+	params := m.ParamsWithoutCtx()
+	for i := range params {
+		if params[i].Name == path[0] {
+			param = &params[i]
+		}
+	}
+
+	if param == nil {
+		return types.Label{}, notApplicable("//misura:label=%s: method has no parameter named '%s'", value, path[0])
+	}
+
+	typ, err := parser.ParseExpr(param.Type)
+	if err != nil {
+		return types.Label{}, notApplicable("//misura:label=%s: unsupported type %s", value, param.Type)
+	}
+
+	label := types.Label{Name: strings.TrimSpace(name), Expr: path[0]}
+	for _, field := range path[1:] {
+		if star, ok := typ.(*ast.StarExpr); ok {
+			label.NilChecks = append(label.NilChecks, label.Expr)
+			typ = star.X
+		}
+
+		st := declaredStruct(typ, decls)
+		if st == nil {
+			return types.Label{}, notApplicable("//misura:label=%s: %s is not a struct declared in the package", value, label.Expr)
+		}
+
+		typ = fieldType(st, field, decls)
+		if typ == nil {
+			return types.Label{}, notApplicable("//misura:label=%s: %s has no field named '%s'", value, label.Expr, field)
+		}
+
+		label.Expr += "." + field
+	}
+
+	switch x := typ.(type) {
+	case *ast.Ident:
+		label.IsString = x.Name == "string"
+	case *ast.SelectorExpr:
+	default:
+		return types.Label{}, notApplicable("//misura:label=%s: %s must be a string, number, bool or a named type", value, label.Expr)
+	}
+
+	return label, nil
+}
+
+// fieldType returns the type of the field of st with the given name,
+// including fields promoted from embedded structs, or nil if there is
+// no such field.
+func fieldType(st *ast.StructType, name string, decls map[string]ast.Expr) ast.Expr {
+	var embedded []*ast.StructType
+	for _, f := range st.Fields.List {
+		for _, n := range f.Names {
+			if n.Name == name {
+				return f.Type
+			}
+		}
+
+		if len(f.Names) != 0 {
+			continue
+		}
+
+		typ := f.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+
+		ident, ok := typ.(*ast.Ident)
+		if !ok {
+			continue
+		}
+
+		if ident.Name == name {
+			return f.Type
+		}
+
+		// fields promoted through pointers may be nil, ignore them
+		if e := declaredStruct(ident, decls); e != nil && e != st && typ == f.Type {
+			embedded = append(embedded, e)
+		}
+	}
+
+	for _, e := range embedded {
+		if typ := fieldType(e, name, decls); typ != nil {
+			return typ
+		}
+	}
+
+	return nil
+}
+
+// declaredStruct returns the struct typ refers to if it's
+// declared in the package, or nil otherwise.
+func declaredStruct(typ ast.Expr, decls map[string]ast.Expr) *ast.StructType {
+	ident, ok := typ.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+
+	st, _ := decls[ident.Name].(*ast.StructType)
+	return st
+}
+
+// errNotApplicable is matched by errors of annotations that can't be
+// applied to a method, i.e. retry on a method without an error result.
+var errNotApplicable = errors.New("annotation is not applicable to the method")
+
+// notApplicableError is an error that matches errNotApplicable.
+type notApplicableError string
+
+func (e notApplicableError) Error() string { return string(e) }
+
+func (e notApplicableError) Is(target error) bool { return target == errNotApplicable }
+
+// notApplicable returns an error matching errNotApplicable.
+func notApplicable(format string, a ...any) error {
+	return notApplicableError(fmt.Sprintf(format, a...))
+}
+
+// applyAnnotation calls apply with the value of the annotation. Method
+// annotations take precedence and must be valid. Type annotations are
+// only applied to methods they are applicable to, but invalid values are
+// still reported.
+func applyAnnotation(key string, typeAnnotations, methodAnnotations types.Annotations, apply func(value string) error) error {
+	if methodAnnotations.Has(key) {
+		return apply(methodAnnotations.Get(key))
+	}
+
+	if typeAnnotations.Has(key) {
+		if err := apply(typeAnnotations.Get(key)); err != nil && !errors.Is(err, errNotApplicable) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func resolveOK(m *types.Method, value string, decls map[string]ast.Expr) error {
+	var (
+		result *types.FuncParam
+		fields []string
+	)
+	if value == "" || strings.HasPrefix(value, ".") {
+		for i := len(m.Results) - 1; i >= 0; i-- {
+			if m.Results[i].Type != "error" {
+				result = &m.Results[i]
+				break
+			}
+		}
+	}
+
+	switch {
+	case value == "":
+		if result == nil {
+			return notApplicable("//misura:ok requires the last result to be a bool")
+		}
+	case strings.HasPrefix(value, "."):
+		if result == nil {
+			return notApplicable("//misura:ok=%s requires a result other than error", value)
+		}
+
+		fields = strings.Split(value[1:], ".")
+	default:
+		name, rest, hasFields := strings.Cut(value, ".")
+		for i := range m.Results {
+			if m.Results[i].Name == name && m.Results[i].Type != "error" {
+				result = &m.Results[i]
+			}
+		}
+
+		if result == nil {
+			return notApplicable("//misura:ok=%s: method has no result named '%s'", value, name)
+		}
+
+		if hasFields {
+			fields = strings.Split(rest, ".")
+		}
+	}
+
+	for _, field := range fields {
+		if !token.IsIdentifier(field) {
+			return fmt.Errorf("//misura:ok=%s: expected [<result>].<field>[.<field>...]", value)
+		}
+	}
+
+	typ, err := parser.ParseExpr(result.Type)
+	if err != nil {
+		return notApplicable("//misura:ok=%s: unsupported type %s", value, result.Type)
+	}
+
+	// fields are resolved the same way as labels, but pointers are not
+	// allowed since the wrapper can't tell if a nil result succeeded
+	expr := result.Name
+	for _, field := range fields {
+		st := declaredStruct(typ, decls)
+		if st == nil {
+			return notApplicable("//misura:ok=%s: %s is not a struct declared in the package", value, expr)
+		}
+
+		typ = fieldType(st, field, decls)
+		if typ == nil {
+			return notApplicable("//misura:ok=%s: %s has no field named '%s'", value, expr, field)
+		}
+
+		expr += "." + field
+	}
+
+	if !isBool(typ, decls) {
+		if value == "" {
+			return notApplicable("//misura:ok requires the last result to be a bool")
+		}
+
+		return fmt.Errorf("//misura:ok=%s: %s must be a bool", value, expr)
+	}
+
+	m.OK = expr
+	return nil
+}
+
+// isBool reports whether typ is bool or a type declared in the package
+// as a bool.
+func isBool(typ ast.Expr, decls map[string]ast.Expr) bool {
+	ident, ok := typ.(*ast.Ident)
+	if !ok {
+		return false
+	}
+
+	if decl, ok := decls[ident.Name].(*ast.Ident); ok {
+		ident = decl
+	}
+
+	return ident.Name == "bool"
+}
@@ -0,0 +1,443 @@
+package wrapper
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+	"time"
+
+	"github.com/itzloop/misura/wrapper/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnnotation(t *testing.T) {
+	cases := []struct {
+		text     string
+		ok       bool
+		target   bool
+		expected types.Annotation
+	}{
+		{text: "//misura:FooType", ok: true, target: true, expected: types.Annotation{Key: "FooType"}},
+		{text: "//misura:redact=password", ok: true, expected: types.Annotation{Key: "redact", Value: "password"}},
+		{text: "//misura:redact", ok: true, expected: types.Annotation{Key: "redact"}},
+		{text: "//misura:unknown=value", ok: true, expected: types.Annotation{Key: "unknown", Value: "value"}},
+		{text: "// misura:FooType", ok: false},
+		{text: "//go:generate misura", ok: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			an, ok := parseAnnotation(c.text)
+			require.Equal(t, c.ok, ok)
+			if !ok {
+				return
+			}
+
+			assert.Equal(t, c.expected, an)
+			assert.Equal(t, c.target, isTarget(an))
+		})
+	}
+}
+
+func TestHandleRedaction(t *testing.T) {
+	m := types.Method{
+		Params: types.FuncParams{
+			{Name: "ctx", Type: "context.Context"},
+			{Name: "user", Type: "string"},
+			{Name: "password", Type: "string"},
+			{Name: "creds", Type: "*Credentials"},
+		},
+		Results: types.FuncParams{
+			{Name: "a", Type: "*Credentials"},
+			{Name: "err", Type: "error"},
+		},
+		Annotations: types.Annotations{
+			{Key: "redact", Value: "password"},
+			{Key: "redact", Value: "*Credentials"},
+		},
+	}
+
+	handleRedaction(&m)
+
+	var redacted []string
+	for _, p := range append(m.Params, m.Results...) {
+		if p.Redact {
+			redacted = append(redacted, p.Name)
+		}
+	}
+
+	assert.Equal(t, []string{"password", "creds", "a"}, redacted)
+}
+
+func TestResolveOK(t *testing.T) {
+	decls := parseDecls(t, `package p
+type Found bool
+type Inner struct{ OK bool }
+type Status struct {
+	Inner   Inner
+	OK      bool
+	Found   Found
+	Code    int
+	Pointer *Inner
+}`)
+
+	cases := []struct {
+		name     string
+		value    string
+		results  types.FuncParams
+		expected string
+		err      bool
+	}{
+		{name: "last_bool", results: types.FuncParams{{Name: "a", Type: "string"}, {Name: "b", Type: "bool"}}, expected: "b"},
+		{name: "last_bool_before_error", results: types.FuncParams{{Name: "found", Type: "bool"}, {Name: "err", Type: "error"}}, expected: "found"},
+		{name: "last_named_bool", results: types.FuncParams{{Name: "a", Type: "Found"}}, expected: "a"},
+		{name: "last_not_bool", results: types.FuncParams{{Name: "a", Type: "int"}}, err: true},
+		{name: "no_results", err: true},
+		{name: "field_of_last", value: ".OK", results: types.FuncParams{{Name: "a", Type: "Status"}}, expected: "a.OK"},
+		{name: "named_field_of_last", value: ".Found", results: types.FuncParams{{Name: "a", Type: "Status"}}, expected: "a.Found"},
+		{name: "field_of_error", value: ".OK", results: types.FuncParams{{Name: "err", Type: "error"}}, err: true},
+		{name: "named", value: "st.Inner.OK", results: types.FuncParams{{Name: "st", Type: "Status"}, {Name: "err", Type: "error"}}, expected: "st.Inner.OK"},
+		{name: "named_bool", value: "ok", results: types.FuncParams{{Name: "v", Type: "string"}, {Name: "ok", Type: "bool"}}, expected: "ok"},
+		{name: "named_not_bool", value: "v", results: types.FuncParams{{Name: "v", Type: "string"}, {Name: "ok", Type: "bool"}}, err: true},
+		{name: "unknown_name", value: "nope", results: types.FuncParams{{Name: "st", Type: "Status"}}, err: true},
+		{name: "missing_field", value: ".Ok", results: types.FuncParams{{Name: "a", Type: "Status"}}, err: true},
+		{name: "field_not_bool", value: ".Code", results: types.FuncParams{{Name: "a", Type: "Status"}}, err: true},
+		{name: "not_struct", value: ".OK", results: types.FuncParams{{Name: "a", Type: "string"}}, err: true},
+		{name: "pointer", value: ".OK", results: types.FuncParams{{Name: "a", Type: "*Status"}}, err: true},
+		{name: "through_pointer", value: "st.Pointer.OK", results: types.FuncParams{{Name: "st", Type: "Status"}}, err: true},
+		{name: "invalid_field", value: ".OK-", results: types.FuncParams{{Name: "a", Type: "Status"}}, err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := types.Method{Results: c.results}
+			err := resolveOK(&m, c.value, decls)
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, m.OK)
+		})
+	}
+}
+
+func TestHandleOK(t *testing.T) {
+	decls := parseDecls(t, `package p
+type Status struct {
+	OK   bool
+	Code int
+}`)
+	status := types.FuncParams{{Name: "a", Type: "Status"}}
+
+	m := types.Method{Results: types.FuncParams{{Name: "a", Type: "int"}}}
+	require.NoError(t, handleOK(&m, types.Annotations{{Key: "ok", Value: ".OK"}}, nil, decls))
+	assert.Empty(t, m.OK)
+
+	m = types.Method{Results: status}
+	require.ErrorContains(t, handleOK(&m, types.Annotations{{Key: "ok", Value: ".Code"}}, nil, decls), "//misura:ok=.Code: a.Code must be a bool")
+
+	m = types.Method{Results: status}
+	require.ErrorContains(t, handleOK(&m, nil, types.Annotations{{Key: "ok", Value: ".Ok"}}, decls), "//misura:ok=.Ok: a has no field named 'Ok'")
+}
+
+func TestHandleRetry(t *testing.T) {
+	withError := types.FuncParams{{Name: "err", Type: "error"}}
+	cases := []struct {
+		name       string
+		typeAn     types.Annotations
+		methodAn   types.Annotations
+		results    types.FuncParams
+		retry      bool
+		attempts   int
+		shouldFail bool
+	}{
+		{name: "not_annotated", results: withError},
+		{name: "method", methodAn: types.Annotations{{Key: "retry"}}, results: withError, retry: true},
+		{name: "method_attempts", methodAn: types.Annotations{{Key: "retry", Value: "5"}}, results: withError, retry: true, attempts: 5},
+		{name: "method_without_error", methodAn: types.Annotations{{Key: "retry"}}, shouldFail: true},
+		{name: "type_without_error", typeAn: types.Annotations{{Key: "retry"}}},
+		{name: "type_invalid_attempts", typeAn: types.Annotations{{Key: "retry", Value: "x"}}, results: withError, shouldFail: true},
+		{name: "method_overrides_type", typeAn: types.Annotations{{Key: "retry", Value: "2"}}, methodAn: types.Annotations{{Key: "retry", Value: "3"}}, results: withError, retry: true, attempts: 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := types.Method{Results: c.results}
+			err := handleRetry(&m, c.typeAn, c.methodAn)
+			if c.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, c.retry, m.Retry)
+			assert.Equal(t, c.attempts, m.RetryAttempts)
+		})
+	}
+}
+
+func TestHandleTimeout(t *testing.T) {
+	cases := []struct {
+		name       string
+		typeAn     types.Annotations
+		methodAn   types.Annotations
+		hasCtx     bool
+		timeout    time.Duration
+		expr       string
+		shouldFail bool
+	}{
+		{name: "not_annotated", hasCtx: true, expr: "0"},
+		{name: "method", methodAn: types.Annotations{{Key: "timeout", Value: "2s"}}, hasCtx: true, timeout: 2 * time.Second, expr: "2 * time.Second"},
+		{name: "fraction", methodAn: types.Annotations{{Key: "timeout", Value: "1.5s"}}, hasCtx: true, timeout: 1500 * time.Millisecond, expr: "1500 * time.Millisecond"},
+		{name: "method_without_ctx", methodAn: types.Annotations{{Key: "timeout", Value: "2s"}}, shouldFail: true},
+		{name: "invalid_duration", methodAn: types.Annotations{{Key: "timeout", Value: "2"}}, hasCtx: true, shouldFail: true},
+		{name: "type_without_ctx", typeAn: types.Annotations{{Key: "timeout", Value: "2s"}}, expr: "0"},
+		{name: "type", typeAn: types.Annotations{{Key: "timeout", Value: "1m"}}, hasCtx: true, timeout: time.Minute, expr: "1 * time.Minute"},
+		{name: "type_invalid_duration", typeAn: types.Annotations{{Key: "timeout", Value: "abc"}}, hasCtx: true, shouldFail: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := types.Method{HasCtx: c.hasCtx}
+			err := handleTimeout(&m, c.typeAn, c.methodAn)
+			if c.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, c.timeout, m.Timeout)
+			assert.Equal(t, c.expr, m.TimeoutExpr())
+		})
+	}
+}
+
+func TestHandleCache(t *testing.T) {
+	withResult := types.FuncParams{{Name: "a", Type: "string"}, {Name: "err", Type: "error"}}
+	slice := types.FuncParams{{Name: "ids", Type: "[]string"}}
+	cases := []struct {
+		name       string
+		typeAn     types.Annotations
+		methodAn   types.Annotations
+		params     types.FuncParams
+		results    types.FuncParams
+		cache      bool
+		ttl        time.Duration
+		key        string
+		shouldFail bool
+	}{
+		{name: "not_annotated", results: withResult},
+		{name: "method", methodAn: types.Annotations{{Key: "cache"}}, results: withResult, cache: true},
+		{name: "method_ttl", methodAn: types.Annotations{{Key: "cache", Value: "1m"}}, results: withResult, cache: true, ttl: time.Minute},
+		{name: "invalid_ttl", methodAn: types.Annotations{{Key: "cache", Value: "1"}}, results: withResult, shouldFail: true},
+		{name: "only_error", methodAn: types.Annotations{{Key: "cache"}}, results: types.FuncParams{{Name: "err", Type: "error"}}, shouldFail: true},
+		{name: "not_comparable", methodAn: types.Annotations{{Key: "cache"}}, params: slice, results: withResult, shouldFail: true},
+		{name: "key", methodAn: types.Annotations{{Key: "cache"}, {Key: "cachekey", Value: "idsKey"}}, params: slice, results: withResult, cache: true, key: "idsKey"},
+		{name: "invalid_key", methodAn: types.Annotations{{Key: "cache"}, {Key: "cachekey", Value: "ids-key"}}, params: slice, results: withResult, shouldFail: true},
+		{name: "key_without_cache", methodAn: types.Annotations{{Key: "cachekey", Value: "idsKey"}}, results: withResult, shouldFail: true},
+		{name: "type_not_comparable", typeAn: types.Annotations{{Key: "cache"}}, params: slice, results: withResult},
+		{name: "type_invalid_ttl", typeAn: types.Annotations{{Key: "cache", Value: "1"}}, results: withResult, shouldFail: true},
+		{name: "type_invalid_key", typeAn: types.Annotations{{Key: "cache"}}, methodAn: types.Annotations{{Key: "cachekey", Value: "ids-key"}}, params: slice, results: withResult, shouldFail: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := types.Method{Params: c.params, Results: c.results}
+			err := handleCache(&m, c.typeAn, c.methodAn, nil, nil)
+			if c.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, c.cache, m.Cache)
+			assert.Equal(t, c.ttl, m.CacheTTL)
+			assert.Equal(t, c.key, m.CacheKey)
+		})
+	}
+}
+
+func TestHandleHedge(t *testing.T) {
+	cases := []struct {
+		name       string
+		typeAn     types.Annotations
+		methodAn   types.Annotations
+		hasCtx     bool
+		hedge      bool
+		delay      time.Duration
+		shouldFail bool
+	}{
+		{name: "not_annotated", hasCtx: true},
+		{name: "method", methodAn: types.Annotations{{Key: "hedge"}}, hasCtx: true, hedge: true},
+		{name: "method_delay", methodAn: types.Annotations{{Key: "hedge", Value: "20ms"}}, hasCtx: true, hedge: true, delay: 20 * time.Millisecond},
+		{name: "invalid_delay", methodAn: types.Annotations{{Key: "hedge", Value: "-1s"}}, hasCtx: true, shouldFail: true},
+		{name: "no_ctx", methodAn: types.Annotations{{Key: "hedge"}}, shouldFail: true},
+		{name: "type_no_ctx", typeAn: types.Annotations{{Key: "hedge"}}},
+		{name: "type", typeAn: types.Annotations{{Key: "hedge", Value: "1s"}}, hasCtx: true, hedge: true, delay: time.Second},
+		{name: "type_invalid_delay", typeAn: types.Annotations{{Key: "hedge", Value: "soon"}}, hasCtx: true, shouldFail: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := types.Method{HasCtx: c.hasCtx}
+			err := handleHedge(&m, c.typeAn, c.methodAn)
+			if c.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, c.hedge, m.Hedge)
+			assert.Equal(t, c.delay, m.HedgeDelay)
+		})
+	}
+}
+
+func TestIsComparable(t *testing.T) {
+	decls := parseDecls(t, `package p
+type ID string
+type IDs []string
+type Store interface{ Get() }
+type Point struct{ X, Y int }
+type Query struct {
+	Point
+	Tags []string
+}
+type Node struct{ Next *Node }`)
+
+	cases := []struct {
+		typ        string
+		comparable bool
+	}{
+		{typ: "string", comparable: true},
+		{typ: "*Query", comparable: true},
+		{typ: "ID", comparable: true},
+		{typ: "Point", comparable: true},
+		{typ: "Node", comparable: true},
+		{typ: "[2]Point", comparable: true},
+		{typ: "time.Time", comparable: true},
+		{typ: "time.Duration", comparable: true},
+		{typ: "url.URL", comparable: true},
+		{typ: "u.Userinfo", comparable: true},
+		{typ: "url.Values"},
+		{typ: "json.RawMessage"},
+		{typ: "u.Values"},
+		{typ: "strings.Builder"},
+		{typ: "time.zone"},
+		{typ: "unknown.Type"},
+		{typ: "chan int", comparable: true},
+		{typ: "[]string"},
+		{typ: "...string"},
+		{typ: "map[string]int"},
+		{typ: "func()"},
+		{typ: "any"},
+		{typ: "error"},
+		{typ: "interface{ Get() }"},
+		{typ: "IDs"},
+		{typ: "Store"},
+		{typ: "Query"},
+		{typ: "[2]Query"},
+		{typ: "struct{ ids []string }"},
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), "", `package p
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+	u "net/url"
+)`, 0)
+	require.NoError(t, err)
+	imported := newImportedTypes(".", f)
+
+	for _, c := range cases {
+		t.Run(c.typ, func(t *testing.T) {
+			assert.Equal(t, c.comparable, isComparable(c.typ, decls, imported))
+		})
+	}
+}
+
+func TestHandleLabels(t *testing.T) {
+	decls := parseDecls(t, `package p
+type Meta struct{ Tenant string }
+type Base struct{ Shard int }
+type Request struct {
+	Base
+	Region string
+	Tags   []string
+	Meta   *Meta
+}`)
+
+	cases := []struct {
+		name       string
+		typeAn     types.Annotations
+		methodAn   types.Annotations
+		labels     []types.Label
+		shouldFail bool
+	}{
+		{name: "not_annotated"},
+		{name: "param", methodAn: types.Annotations{{Key: "label", Value: "kind:kind"}}, labels: []types.Label{{Name: "kind", Expr: "kind", IsString: true}}},
+		{name: "field", methodAn: types.Annotations{{Key: "label", Value: "region:req.Region"}}, labels: []types.Label{{Name: "region", Expr: "req.Region", NilChecks: []string{"req"}, IsString: true}}},
+		{name: "promoted", methodAn: types.Annotations{{Key: "label", Value: "shard:req.Shard"}}, labels: []types.Label{{Name: "shard", Expr: "req.Shard", NilChecks: []string{"req"}}}},
+		{name: "nested", methodAn: types.Annotations{{Key: "label", Value: "tenant:req.Meta.Tenant"}}, labels: []types.Label{{Name: "tenant", Expr: "req.Meta.Tenant", NilChecks: []string{"req", "req.Meta"}, IsString: true}}},
+		{name: "missing_field", methodAn: types.Annotations{{Key: "label", Value: "zone:req.Zone"}}, shouldFail: true},
+		{name: "missing_param", methodAn: types.Annotations{{Key: "label", Value: "zone:zone"}}, shouldFail: true},
+		{name: "ctx", methodAn: types.Annotations{{Key: "label", Value: "ctx:ctx"}}, shouldFail: true},
+		{name: "slice", methodAn: types.Annotations{{Key: "label", Value: "tags:req.Tags"}}, shouldFail: true},
+		{name: "not_struct", methodAn: types.Annotations{{Key: "label", Value: "kind:kind.Name"}}, shouldFail: true},
+		{name: "invalid", methodAn: types.Annotations{{Key: "label", Value: "req.Region"}}, shouldFail: true},
+		{name: "duplicate", methodAn: types.Annotations{{Key: "label", Value: "kind:kind,kind:req.Region"}}, shouldFail: true},
+		{name: "type_not_applicable", typeAn: types.Annotations{{Key: "label", Value: "zone:zone"}}},
+		{name: "type_invalid", typeAn: types.Annotations{{Key: "label", Value: "req.Region"}}, shouldFail: true},
+		{
+			name:     "type_overridden",
+			typeAn:   types.Annotations{{Key: "label", Value: "kind:req.Region"}},
+			methodAn: types.Annotations{{Key: "label", Value: "kind:kind"}},
+			labels:   []types.Label{{Name: "kind", Expr: "kind", IsString: true}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := types.Method{
+				HasCtx: true,
+				Ctx:    "ctx",
+				Params: types.FuncParams{
+					{Name: "ctx", Type: "context.Context"},
+					{Name: "req", Type: "*Request"},
+					{Name: "kind", Type: "string"},
+				},
+			}
+			err := handleLabels(&m, c.typeAn, c.methodAn, decls)
+			if c.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, c.labels, m.Labels)
+		})
+	}
+}
+
+// parseDecls returns types declared in src by name.
+func parseDecls(t *testing.T, src string) map[string]ast.Expr {
+	t.Helper()
+
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	require.NoError(t, err)
+
+	decls := map[string]ast.Expr{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok {
+			decls[ts.Name.Name] = ts.Type
+		}
+		return true
+	})
+
+	return decls
+}
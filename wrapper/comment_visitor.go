@@ -6,7 +6,6 @@ import (
 	"go/token"
 	"os"
 	"path"
-	"strings"
 )
 
 type CommentVisitor struct {
@@ -46,11 +45,12 @@ func (cv *CommentVisitor) Visit(nRaw ast.Node) ast.Visitor {
 
 	switch n := nRaw.(type) {
 	case *ast.Comment:
-		if !strings.Contains(n.Text, "//misura:") {
+		an, ok := parseAnnotation(n.Text)
+		if !ok || !isTarget(an) {
 			return cv
 		}
 
-		cv.targets = append(cv.targets, strings.Replace(n.Text, "//misura:", "", 1))
+		cv.targets = append(cv.targets, an.Key)
 	}
 
 	return cv
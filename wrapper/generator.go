@@ -47,9 +47,75 @@ type GeneratorOpts struct {
 	// 2. total
 	// 3. error
 	// 4. success
-	// 5. all
+	// 5. panic
+	// 6. inflight
+	// 7. miss
+	// 8. all
 	// If all is specified then others will be ignored.
 	Metrics types.Strings
+
+	// Wrappers will be used to decide what wrappers to generate.
+	// Possible values are:
+	// 1. metrics
+	// 2. retry
+	// 3. breaker
+	// 4. timeout
+	// 5. limit
+	// 6. interceptor
+	// 7. hooks
+	// 8. fake
+	// 9. record
+	// 10. fault
+	// 11. cache
+	// 12. hedge
+	// If empty, only metrics wrapper will be generated.
+	Wrappers types.Strings
+
+	// RecoverPanics, if set to true, will convert panics into errors
+	// for methods whose last result is an error. Other methods
+	// will re-panic.
+	RecoverPanics bool
+
+	// ClassifyErrors, if set to true, will pass the class of errors
+	// to Failure. Errors are classified using misura.ErrorClassifier
+	// passed to the constructor.
+	ClassifyErrors bool
+
+	// Sample, if set to true, will only record calls sampled by
+	// misura.Sampler passed to the constructor. The rate is passed
+	// to metrics in ctx, see misura.SampleRate.
+	Sample bool
+
+	// Capture, if set to true, will capture parameters and results
+	// of each call and pass them to metrics in a misura.CallInfo
+	// stored in ctx. Use //misura:redact=<name|type> to avoid
+	// capturing sensitive values.
+	Capture bool
+
+	// ConstructorErrors, if set to true, will make constructors of
+	// generated wrappers return an error instead of panicking when
+	// wrapped is nil.
+	ConstructorErrors bool
+
+	// Bench, if set to true, will generate benchmarks for each method
+	// of the metrics wrapper in <filename>_<suffix>_bench_test.go.
+	Bench bool
+
+	// Contract, if set to true, will generate tests for the metrics
+	// wrapper in <filename>_<suffix>_contract_test.go. They check the
+	// wrapper implements the interface, forwards parameters and results
+	// unchanged and calls metrics as configured.
+	Contract bool
+
+	// SkipErrorless, if set to true, will keep the old behavior for
+	// methods without an error result. They will not be timed and
+	// Success will not be called for them.
+	SkipErrorless bool
+
+	// Options, if set to true, will generate New<Wrapper>(wrapped, ...misura.Option)
+	// for the metrics wrapper instead of the positional constructor. Calls
+	// are timed using the misura.Clock and labels passed to it.
+	Options bool
 }
 
 type TemplateVals struct {
@@ -61,11 +127,49 @@ type TemplateVals struct {
 	DurationName    string
 	RandomHex       string
 
+	// wrappers
+	HasMetricsWrapper     bool
+	HasRetryWrapper       bool
+	HasBreakerWrapper     bool
+	HasTimeoutWrapper     bool
+	HasLimitWrapper       bool
+	HasInterceptorWrapper bool
+	HasHooksWrapper       bool
+	HasFake               bool
+	HasRecordWrapper      bool
+	HasFaultWrapper       bool
+	HasCacheWrapper       bool
+	HasHedgeWrapper       bool
+
 	// metrics
 	HasDuration bool
 	HasTotal    bool
 	HasError    bool
 	HasSuccess  bool
+	HasPanic    bool
+	HasInflight bool
+	HasMiss     bool
+
+	HasCapture  bool
+	HasRecover  bool
+	HasClassify bool
+	HasSample   bool
+
+	// HasCtorError is set when the constructor of the metrics
+	// wrapper returns an error.
+	HasCtorError bool
+
+	// HasOptions is set when the constructor of the metrics
+	// wrapper takes misura.Option values.
+	HasOptions bool
+
+	// MeasureErrorless is set when methods without an error result
+	// should be timed and reported as success.
+	MeasureErrorless bool
+
+	// ImportRuntime is set when the wrapper uses
+	// github.com/itzloop/misura/misura.
+	ImportRuntime bool
 }
 
 type WrapperGenerator struct {
@@ -114,7 +218,6 @@ func NewWrapperGenerator(opts GeneratorOpts) (*WrapperGenerator, error) {
 func (w *WrapperGenerator) Generate(outPath, filename string, tmplVals TemplateVals) error {
 	var (
 		b                = &bytes.Buffer{}
-		processed        []byte
 		filenameSuffixed = fmt.Sprintf("%s.%s.go", strings.Replace(filename, ".go", "", 1), w.opts.Suffix)
 		p                = path.Join(outPath, filenameSuffixed)
 		err              error
@@ -144,6 +247,57 @@ func (w *WrapperGenerator) Generate(outPath, filename string, tmplVals TemplateV
 		}
 	}
 
+	// panic, inflight and miss add methods to metrics, so they are not
+	// included in all and must be enabled explicitly
+	tmplVals.HasPanic = w.opts.Metrics.Exists("panic")
+	tmplVals.HasInflight = w.opts.Metrics.Exists("inflight")
+	tmplVals.HasMiss = w.opts.Metrics.Exists("miss")
+
+	// Miss is only useful if there are methods annotated with //misura:ok
+	hasOK, hasLabels := false, false
+	for _, m := range tmplVals.MethodList {
+		hasOK = hasOK || m.OK != ""
+		hasLabels = hasLabels || len(m.Labels) != 0
+	}
+	tmplVals.HasMiss = tmplVals.HasMiss && hasOK
+
+	tmplVals.HasMetricsWrapper = len(w.opts.Wrappers) == 0 || w.opts.Wrappers.Exists("metrics")
+	tmplVals.HasRetryWrapper = w.opts.Wrappers.Exists("retry")
+	tmplVals.HasBreakerWrapper = w.opts.Wrappers.Exists("breaker")
+	tmplVals.HasTimeoutWrapper = w.opts.Wrappers.Exists("timeout")
+	tmplVals.HasLimitWrapper = w.opts.Wrappers.Exists("limit")
+	tmplVals.HasInterceptorWrapper = w.opts.Wrappers.Exists("interceptor")
+	tmplVals.HasHooksWrapper = w.opts.Wrappers.Exists("hooks")
+	tmplVals.HasFake = w.opts.Wrappers.Exists("fake")
+	tmplVals.HasRecordWrapper = w.opts.Wrappers.Exists("record")
+	tmplVals.HasFaultWrapper = w.opts.Wrappers.Exists("fault")
+	tmplVals.HasCacheWrapper = w.opts.Wrappers.Exists("cache")
+	tmplVals.HasHedgeWrapper = w.opts.Wrappers.Exists("hedge")
+
+	tmplVals.HasCapture = w.opts.Capture
+	tmplVals.HasRecover = w.opts.RecoverPanics
+	tmplVals.HasClassify = w.opts.ClassifyErrors && tmplVals.HasError
+	tmplVals.HasSample = w.opts.Sample
+	tmplVals.HasCtorError = w.opts.ConstructorErrors
+	tmplVals.HasOptions = w.opts.Options
+	tmplVals.MeasureErrorless = !w.opts.SkipErrorless
+	tmplVals.ImportRuntime = tmplVals.HasRetryWrapper ||
+		tmplVals.HasBreakerWrapper ||
+		tmplVals.HasTimeoutWrapper ||
+		tmplVals.HasLimitWrapper ||
+		tmplVals.HasInterceptorWrapper ||
+		tmplVals.HasRecordWrapper ||
+		tmplVals.HasFaultWrapper ||
+		tmplVals.HasCacheWrapper ||
+		tmplVals.HasHedgeWrapper ||
+		(tmplVals.HasMetricsWrapper && (tmplVals.HasCapture || tmplVals.HasRecover || tmplVals.HasClassify || tmplVals.HasSample || tmplVals.HasCtorError || tmplVals.HasOptions || hasLabels || (hasOK && !tmplVals.HasMiss && tmplVals.HasError)))
+
+	if tmplVals.HasFake {
+		if err = fakeNameCollision(tmplVals.WrapperTypeName, tmplVals.MethodList); err != nil {
+			return err
+		}
+	}
+
 	tmplVals.RandomHex, err = getRandomHex(p, tmplVals.RandomHex)
 	if err != nil {
 		return err
@@ -153,23 +307,52 @@ func (w *WrapperGenerator) Generate(outPath, filename string, tmplVals TemplateV
 		return err
 	}
 
-	processed, err = formatImports(p, b, w.opts.FormatImports)
-	if err != nil {
+	if err = w.write(p, b); err != nil {
 		return err
 	}
 
-	f, err := os.Create(p)
+	if w.opts.Bench && tmplVals.HasMetricsWrapper {
+		if err = w.generateTest(outPath, filename, "bench", tmplVals); err != nil {
+			return err
+		}
+	}
+
+	if w.opts.Contract && tmplVals.HasMetricsWrapper {
+		if err = w.generateTest(outPath, filename, "contract", tmplVals); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generateTest executes <kind>.gotmpl and writes it to
+// <filename>_<suffix>_<kind>_test.go.
+func (w *WrapperGenerator) generateTest(outPath, filename, kind string, tmplVals TemplateVals) error {
+	b := &bytes.Buffer{}
+	if err := w.tmpl.ExecuteTemplate(b, kind+".gotmpl", tmplVals); err != nil {
+		return err
+	}
+
+	testFilename := fmt.Sprintf("%s_%s_%s_test.go", strings.Replace(filename, ".go", "", 1), w.opts.Suffix, kind)
+	return w.write(path.Join(outPath, testFilename), b)
+}
+
+func (w *WrapperGenerator) write(p string, b *bytes.Buffer) error {
+	processed, err := formatImports(p, b, w.opts.FormatImports)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("writing to %s\n", f.Name())
-	_, err = f.Write(processed)
+	f, err := os.Create(p)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	return nil
+	fmt.Printf("writing to %s\n", f.Name())
+	_, err = f.Write(processed)
+	return err
 }
 
 func formatImports(filename string, b *bytes.Buffer, format bool) ([]byte, error) {
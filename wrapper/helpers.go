@@ -1,5 +1,11 @@
 package wrapper
 
+import (
+	"fmt"
+
+	"github.com/itzloop/misura/wrapper/types"
+)
+
 func genNameHelper(count int) func() string {
 	start := -1
 	if count < 1 {
@@ -22,3 +28,49 @@ func genNameHelper(count int) func() string {
 		return string([]byte(alphabet)[start:start+count]) + string([]byte(alphabet)[0:start+count-len(alphabet)])
 	}
 }
+
+// skipNamesHelper wraps a name generator created by genNameHelper and
+// skips names that are already used by parameters or results. w is
+// always skipped since it is the receiver of generated methods.
+func skipNamesHelper(f func() string, names []string) func() string {
+	return func() string {
+		for {
+			n := f()
+			if n == "w" {
+				continue
+			}
+
+			used := false
+			for _, name := range names {
+				if n == name {
+					used = true
+					break
+				}
+			}
+
+			if !used {
+				return n
+			}
+		}
+	}
+}
+
+// fakeNameCollision returns an error if a method of the interface has
+// the same name as a field or helper method generated for <intr>Fake.
+func fakeNameCollision(intr string, methods []types.Method) error {
+	generated := map[string]bool{"Reset": true, "mu": true}
+	for _, m := range methods {
+		generated[m.MethodName+"Func"] = true
+		generated[m.MethodName+"Calls"] = true
+		generated[m.MethodName+"CallCount"] = true
+		generated["calls"+m.MethodName] = true
+	}
+
+	for _, m := range methods {
+		if generated[m.MethodName] {
+			return fmt.Errorf("%s collides with a field or method of %sFake", m.MethodName, intr)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package testsamples
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+)
+
+type Status struct {
+	OK   bool
+	Code int
+}
+
+//misura:ok
+type OKResults interface {
+	Get(k string) (string, bool)
+	Lookup(ctx context.Context, k string) (v []byte, found bool, err error)
+	//misura:ok=.OK
+	Send(msg string) Status
+	//misura:ok=st.OK
+	SendNamed(msg string) (st Status, err error)
+	Len() int
+}
+
+type InvalidOK interface {
+	//misura:ok
+	Len() int
+}
+
+type InvalidOKField interface {
+	//misura:ok=.Ok
+	Send(msg string) Status
+}
+
+type Retries interface {
+	//misura:retry
+	Get(ctx context.Context, id string) (*Status, error)
+	//misura:retry=5
+	Do(id string) error
+	Len() int
+	Publish(msg string)
+}
+
+type InvalidRetry interface {
+	//misura:retry=-1
+	Do(id string) error
+}
+
+type Timeouts interface {
+	//misura:timeout=1500ms
+	Get(ctx context.Context, id string) (*Status, error)
+	Notify(ctx context.Context, msg string)
+	Len() int
+}
+
+type Variadic interface {
+	List(ctx context.Context, prefix string, ids ...string) ([]Status, error)
+	Len() int
+}
+
+type Cached interface {
+	//misura:cache=30s
+	Get(ctx context.Context, id string) (*Status, error)
+	//misura:cache
+	//misura:cachekey=tagsKey
+	Tags(ids ...string) map[string]string
+	Len() int
+}
+
+func tagsKey(ids []string) string {
+	return strings.Join(ids, ",")
+}
+
+type InvalidCache interface {
+	//misura:cache
+	Tags(ids ...string) map[string]string
+}
+
+type CacheQuery struct {
+	Region string
+	Tags   []string
+}
+
+type InvalidCacheStruct interface {
+	//misura:cache
+	Find(q CacheQuery) (string, error)
+}
+
+type InvalidCacheInterface interface {
+	//misura:cache
+	Find(q any) (string, error)
+}
+
+type InvalidCacheImported interface {
+	//misura:cache
+	Find(q url.Values) (string, error)
+}
+
+//misura:hedge
+type Hedged interface {
+	//misura:hedge=50ms
+	Get(ctx context.Context, id string) (*Status, error)
+	Notify(ctx context.Context, msg string)
+	Len() int
+}
+
+type InvalidHedge interface {
+	//misura:hedge
+	Len() int
+}
+
+type LabelMeta struct {
+	Tenant string
+}
+
+type LabelBase struct {
+	Shard int
+}
+
+type LabelRequest struct {
+	LabelBase
+	Region string
+	Meta   *LabelMeta
+}
+
+//misura:label=kind:kind
+type Labeled interface {
+	//misura:label=region:req.Region,shard:req.Shard
+	//misura:label=tenant:req.Meta.Tenant
+	Get(ctx context.Context, req *LabelRequest) (string, error)
+	List(ctx context.Context, kind string) ([]string, error)
+	Len() int
+}
+
+type InvalidLabel interface {
+	//misura:label=zone:req.Zone
+	Get(ctx context.Context, req LabelRequest) error
+}
+
+type InvalidTimeout interface {
+	//misura:timeout=1s
+	Len() int
+}
+
+//misura:timeout=abc
+type InvalidTypeTimeout interface {
+	Get(ctx context.Context, id string) (*Status, error)
+	Len() int
+}
+
+type Shadowed interface {
+	//misura:retry=2
+	Apply(ctx context.Context, f func() error, r io.Reader, policy string) (int, error)
+}
+
+type Resettable interface {
+	Get(id string) (*Status, error)
+	Reset()
+}
+
+type CallsCollision interface {
+	Get(id string) (*Status, error)
+	GetCalls() int
+}
@@ -2,6 +2,7 @@ package testsamples
 
 //misura:MagicNamedParamsAndResults
 type MagicNamedParamsAndResults interface {
+    //misura:redact=c
     Method1(a string, b *int, c []byte) (s string, err error)
 }
 
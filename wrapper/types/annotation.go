@@ -0,0 +1,50 @@
+package types
+
+import "strings"
+
+// Annotation is a //misura:<key>=<value> comment placed on a
+// method or a type.
+type Annotation struct {
+	Key   string
+	Value string
+}
+
+type Annotations []Annotation
+
+func (a Annotations) Has(key string) bool {
+	for _, an := range a {
+		if an.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get returns the value of the first annotation with the given key.
+func (a Annotations) Get(key string) string {
+	for _, an := range a {
+		if an.Key == key {
+			return an.Value
+		}
+	}
+
+	return ""
+}
+
+// Values returns values of all annotations with the given key.
+// Comma seperated values are splitted.
+func (a Annotations) Values(key string) Strings {
+	var values Strings
+	for _, an := range a {
+		if an.Key != key || strings.TrimSpace(an.Value) == "" {
+			continue
+		}
+
+		for _, v := range strings.Split(an.Value, ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+	}
+
+	return values
+}
@@ -8,6 +8,52 @@ import (
 type FuncParam struct {
 	Name string
 	Type string
+
+	// Redact is set when the value must not be captured.
+	Redact bool
+}
+
+// SliceType is the type of the parameter with variadic
+// types (i.e. ...string) converted to slices.
+func (f FuncParam) SliceType() string {
+	if strings.HasPrefix(f.Type, "...") {
+		return "[]" + strings.TrimPrefix(f.Type, "...")
+	}
+
+	return f.Type
+}
+
+// FieldName is the name of the parameter exported,
+// to be used as a struct field.
+func (f FuncParam) FieldName() string {
+	return strings.ToUpper(f.Name[:1]) + f.Name[1:]
+}
+
+// SampleValue is a go expression of a value of the parameter type which is
+// not the zero value when possible. Variadic types are converted to slices.
+// This is useful to check values are passed unchanged.
+func (f FuncParam) SampleValue() string {
+	typ := f.SliceType()
+	switch {
+	case typ == "string":
+		return `"misura"`
+	case typ == "bool":
+		return "true"
+	case typ == "int" || typ == "int8" || typ == "int16" || typ == "int32" || typ == "int64" ||
+		typ == "uint" || typ == "uint8" || typ == "uint16" || typ == "uint32" || typ == "uint64" ||
+		typ == "byte" || typ == "rune" || typ == "float32" || typ == "float64":
+		return typ + "(42)"
+	case typ == "error":
+		return `errors.New("misura")`
+	case strings.HasPrefix(typ, "*"):
+		return "new(" + strings.TrimPrefix(typ, "*") + ")"
+	case strings.HasPrefix(typ, "[]"):
+		return "make(" + typ + ", 1)"
+	case strings.HasPrefix(typ, "map["):
+		return "make(" + typ + ")"
+	}
+
+	return "*new(" + typ + ")"
 }
 
 type FuncParams []FuncParam
@@ -54,3 +100,25 @@ func (f FuncParams) Join() string {
 
 	return str
 }
+
+// JoinSlices is the same as Join but variadic types are converted to slices.
+// This is useful when passing parameters to other functions as values.
+func (f FuncParams) JoinSlices() string {
+	params := make([]string, 0, len(f))
+	for _, p := range f {
+		params = append(params, fmt.Sprintf("%s %s", p.Name, p.SliceType()))
+	}
+
+	return strings.Join(params, ", ")
+}
+
+// JoinNamesSlices is the same as JoinNames but variadic
+// parameters are passed as slices.
+func (f FuncParams) JoinNamesSlices() string {
+	names := make([]string, 0, len(f))
+	for _, p := range f {
+		names = append(names, p.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
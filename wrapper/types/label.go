@@ -0,0 +1,41 @@
+package types
+
+import "strings"
+
+// Label is a label extracted from the parameters of a method
+// annotated with //misura:label=<name>:<param>[.<field>...].
+type Label struct {
+	// Name is the name of the label passed to metrics.
+	Name string
+
+	// Expr is the go expression of the value, i.e. req.Region.
+	Expr string
+
+	// NilChecks are pointers in Expr that must not be nil
+	// to evaluate it, i.e. req or req.Meta.
+	NilChecks []string
+
+	// IsString is set when Expr is a string and doesn't
+	// need to be formatted.
+	IsString bool
+}
+
+// ValueExpr is a go expression of the label value as a string.
+func (l Label) ValueExpr() string {
+	if l.IsString {
+		return l.Expr
+	}
+
+	return "fmt.Sprint(" + l.Expr + ")"
+}
+
+// Guard is a go expression which is true when Expr can be evaluated
+// or empty if it can always be evaluated.
+func (l Label) Guard() string {
+	checks := make([]string, 0, len(l.NilChecks))
+	for _, c := range l.NilChecks {
+		checks = append(checks, c+" != nil")
+	}
+
+	return strings.Join(checks, " && ")
+}
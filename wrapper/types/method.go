@@ -1,5 +1,10 @@
 package types
 
+import (
+	"fmt"
+	"time"
+)
+
 type Method struct {
 	MethodSigFull    string
 	MethodName       string
@@ -9,4 +14,101 @@ type Method struct {
 	HasError         bool
 	HasCtx           bool
 	Ctx              string
+	Params           FuncParams
+	Results          FuncParams
+	Annotations      Annotations
+
+	// OK is the expression that decides if the call failed
+	// when the method is annotated with //misura:ok.
+	OK string
+
+	// Retry is set when the method is annotated with //misura:retry.
+	// RetryAttempts overrides max attempts of the policy if not zero.
+	Retry         bool
+	RetryAttempts int
+
+	// Timeout is the value of //misura:timeout.
+	Timeout time.Duration
+
+	// Cache is set when the method is annotated with //misura:cache.
+	// CacheTTL overrides TTL of the policy if not zero and CacheKey
+	// is the function set by //misura:cachekey.
+	Cache    bool
+	CacheTTL time.Duration
+	CacheKey string
+
+	// Hedge is set when the method is annotated with //misura:hedge
+	// and HedgeDelay is its value.
+	Hedge      bool
+	HedgeDelay time.Duration
+
+	// Labels are extracted from parameters and passed to metrics
+	// when the method is annotated with //misura:label.
+	Labels []Label
+}
+
+// LastResultIsError reports whether the last result of the method is an error.
+func (m Method) LastResultIsError() bool {
+	return len(m.Results) > 0 && m.Results[len(m.Results)-1].Type == "error"
+}
+
+// ParamsWithoutCtx returns parameters except the context.Context.
+func (m Method) ParamsWithoutCtx() FuncParams {
+	params := make(FuncParams, 0, len(m.Params))
+	for _, p := range m.Params {
+		if !m.HasCtx || p.Name != m.Ctx {
+			params = append(params, p)
+		}
+	}
+
+	return params
+}
+
+// TimeoutExpr is Timeout as a go expression, i.e. 2 * time.Second.
+func (m Method) TimeoutExpr() string {
+	return durationExpr(m.Timeout)
+}
+
+// CacheTTLExpr is CacheTTL as a go expression, i.e. 2 * time.Second.
+func (m Method) CacheTTLExpr() string {
+	return durationExpr(m.CacheTTL)
+}
+
+// HedgeDelayExpr is HedgeDelay as a go expression, i.e. 2 * time.Second.
+func (m Method) HedgeDelayExpr() string {
+	return durationExpr(m.HedgeDelay)
+}
+
+func durationExpr(d time.Duration) string {
+	if d == 0 {
+		return "0"
+	}
+
+	units := []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	}
+
+	for _, u := range units {
+		if d%u.d == 0 {
+			return fmt.Sprintf("%d * %s", d/u.d, u.name)
+		}
+	}
+
+	return fmt.Sprintf("%d * time.Nanosecond", d)
+}
+
+// MethodSigNamed is the same as MethodSigFull but results are always named.
+func (m Method) MethodSigNamed() string {
+	if len(m.Results) == 0 {
+		return fmt.Sprintf("%s(%s)", m.MethodName, m.Params.Join())
+	}
+
+	return fmt.Sprintf("%s(%s) (%s)", m.MethodName, m.Params.Join(), m.Results.Join())
 }
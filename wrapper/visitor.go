@@ -6,10 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/importer"
 	"go/parser"
 	"go/token"
+	gotypes "go/types"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/itzloop/misura/wrapper/types"
@@ -29,6 +33,18 @@ type TypeVisitor struct {
 	packageName string
 	imports     string
 
+	// typeDoc is the doc of the last type declaration. For
+	// declarations with a single type, doc is attached to
+	// ast.GenDecl instead of ast.TypeSpec.
+	typeDoc *ast.CommentGroup
+
+	// typeDecls are types declared in the package by name, used
+	// to resolve fields and types referenced by annotations.
+	typeDecls map[string]ast.Expr
+
+	// importedTypes resolves types of packages imported by the file.
+	importedTypes importedTypes
+
 	text []byte
 
 	// TODO make this interface
@@ -54,10 +70,91 @@ func (t *TypeVisitor) Walk() error {
 		return err
 	}
 
+	t.typeDecls = packageTypes(path.Dir(t.opts.FilePath))
+	t.importedTypes = newImportedTypes(path.Dir(t.opts.FilePath), root)
 	ast.Walk(t, root)
 	return t.err
 }
 
+// packageTypes returns types declared in go files of dir by name.
+// Files that can't be parsed are ignored.
+func packageTypes(dir string) map[string]ast.Expr {
+	decls := map[string]ast.Expr{}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			if ts, ok := n.(*ast.TypeSpec); ok {
+				decls[ts.Name.Name] = ts.Type
+			}
+
+			return true
+		})
+	}
+
+	return decls
+}
+
+// importedTypes returns the type pkg.name, or nil if it can't be resolved.
+type importedTypes func(pkg, name string) gotypes.Type
+
+// newImportedTypes resolves types of packages imported by file. Packages
+// are type checked from source in dir the first time they are used.
+func newImportedTypes(dir string, file *ast.File) importedTypes {
+	imp := importer.ForCompiler(token.NewFileSet(), "source", nil).(gotypes.ImporterFrom)
+
+	lookup := func(spec *ast.ImportSpec, pkg, name string) gotypes.Type {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil
+		}
+
+		p, err := imp.ImportFrom(importPath, dir, 0)
+		if err != nil || spec.Name == nil && p.Name() != pkg {
+			return nil
+		}
+
+		obj, ok := p.Scope().Lookup(name).(*gotypes.TypeName)
+		if !ok || !obj.Exported() {
+			return nil
+		}
+
+		return obj.Type()
+	}
+
+	return func(pkg, name string) gotypes.Type {
+		for _, spec := range file.Imports {
+			if spec.Name != nil && spec.Name.Name == pkg {
+				return lookup(spec, pkg, name)
+			}
+		}
+
+		// imports without a name whose path ends with pkg are tried
+		// first, since it's usually the name of the package
+		for _, first := range []bool{true, false} {
+			for _, spec := range file.Imports {
+				if spec.Name != nil || (path.Base(strings.Trim(spec.Path.Value, `"`)) == pkg) != first {
+					continue
+				}
+
+				if typ := lookup(spec, pkg, name); typ != nil {
+					return typ
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
 func (t *TypeVisitor) Visit(nRaw ast.Node) ast.Visitor {
 	if nRaw == nil {
 		return nil
@@ -67,6 +164,11 @@ func (t *TypeVisitor) Visit(nRaw ast.Node) ast.Visitor {
 	case *ast.File:
 		t.packageName = n.Name.String()
 	case *ast.GenDecl:
+		if n.Tok == token.TYPE {
+			t.typeDoc = n.Doc
+			return t
+		}
+
 		// we only care about import statements
 		if n.Tok.String() != "import" {
 			return t
@@ -88,9 +190,15 @@ func (t *TypeVisitor) Visit(nRaw ast.Node) ast.Visitor {
 				return nil
 			}
 
-			err := t.handleInterface(n.Name.String(), x)
+			doc := n.Doc
+			if doc == nil {
+				doc = t.typeDoc
+			}
+
+			err := t.handleInterface(n.Name.String(), x, parseAnnotations(doc))
 			if err != nil {
-				panic(err)
+				t.err = fmt.Errorf("%s: %w", n.Name.String(), err)
+				return nil
 			}
 
 			// we are done with this interface do not proceed further.
@@ -116,25 +224,52 @@ func (t *TypeVisitor) Visit(nRaw ast.Node) ast.Visitor {
 	return t
 }
 
-func (t *TypeVisitor) handleInterface(intrName string, intr *ast.InterfaceType) error {
+func (t *TypeVisitor) handleInterface(intrName string, intr *ast.InterfaceType, annotations types.Annotations) error {
 	if t.packageName == "" {
 		return errors.New("TypeVisitor: package name can't be empty")
 	}
 
 	methods := make([]types.Method, 0, cap(intr.Methods.List))
 	for _, m := range intr.Methods.List {
+		methodAnnotations := parseAnnotations(m.Doc)
 		method := types.Method{
 			MethodSigFull: string(t.text[m.Pos()-1 : m.End()-1]),
 			MethodName:    m.Names[0].String(),
+			// type annotations apply to all methods
+			Annotations: append(append(types.Annotations{}, annotations...), methodAnnotations...),
 		}
 		ft, ok := m.Type.(*ast.FuncType)
 		if !ok {
 			return errors.New("TODO: don't want to think about this now :)")
 		}
 
-		f := genNameHelper(1)
-		t.handleParams(&method, ft.Params, f)
-		t.handleResults(&method, ft.Results, f)
+		f := skipNamesHelper(genNameHelper(1), fieldNames(ft.Params, ft.Results))
+		method.Params = t.handleParams(&method, ft.Params, f)
+		method.Results = t.handleResults(&method, ft.Results, f)
+		handleRedaction(&method)
+		if err := handleOK(&method, annotations, methodAnnotations, t.typeDecls); err != nil {
+			return fmt.Errorf("%s: %w", method.MethodName, err)
+		}
+
+		if err := handleRetry(&method, annotations, methodAnnotations); err != nil {
+			return fmt.Errorf("%s: %w", method.MethodName, err)
+		}
+
+		if err := handleTimeout(&method, annotations, methodAnnotations); err != nil {
+			return fmt.Errorf("%s: %w", method.MethodName, err)
+		}
+
+		if err := handleCache(&method, annotations, methodAnnotations, t.typeDecls, t.importedTypes); err != nil {
+			return fmt.Errorf("%s: %w", method.MethodName, err)
+		}
+
+		if err := handleHedge(&method, annotations, methodAnnotations); err != nil {
+			return fmt.Errorf("%s: %w", method.MethodName, err)
+		}
+
+		if err := handleLabels(&method, annotations, methodAnnotations, t.typeDecls); err != nil {
+			return fmt.Errorf("%s: %w", method.MethodName, err)
+		}
 
 		// finally add current method to the methods slice, to use them when
 		// populating templatess.
@@ -335,3 +470,21 @@ func (t *TypeVisitor) handleResults(m *types.Method, results *ast.FieldList, f f
 
 	return resultNames
 }
+
+// fieldNames returns names used in the given field lists.
+func fieldNames(lists ...*ast.FieldList) []string {
+	var names []string
+	for _, l := range lists {
+		if l == nil {
+			continue
+		}
+
+		for _, field := range l.List {
+			for _, name := range field.Names {
+				names = append(names, name.String())
+			}
+		}
+	}
+
+	return names
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
@@ -66,10 +67,640 @@ func TestWrapper(t *testing.T) {
 
 }
 
+func TestWrapperCapture(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Capture:       true,
+	}), wd, "magic_comment.go", []string{"MagicNamedParamsAndResults"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "magic_comment.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), `"github.com/itzloop/misura/misura"`)
+	require.Contains(t, string(generated), `misura.Capture("a", a)`)
+	require.Contains(t, string(generated), `misura.Redact("c")`)
+	require.Contains(t, string(generated), `misura.Capture("s", s)`)
+}
+
+func TestWrapperRecover(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all", "panic"},
+		RecoverPanics: true,
+	}), wd, "test.go", []string{"UnnamedAndNamedParamsAndResults", "NoResult"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "test.UnnamedAndNamedParamsAndResults.misura.go"))
+	require.NoError(t, err)
+	// results must be named to be able to set err in defer and should
+	// not conflict with parameters.
+	require.Contains(t, string(generated), `Method2(a string, b *int, c []byte) (d string, err error)`)
+	require.Contains(t, string(generated), `err = misura.NewPanicError(r)`)
+
+	generated, err = os.ReadFile(path.Join(wd, "test.NoResult.misura.go"))
+	require.NoError(t, err)
+	require.NotContains(t, string(generated), `misura.NewPanicError`)
+	require.Contains(t, string(generated), `panic(r)`)
+}
+
+func TestWrapperInflight(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all", "inflight"},
+	}), wd, "test.go", []string{"NoResult"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "test.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "Inflight(ctx context.Context, name, pkg, intr, method string, delta int)")
+	require.Contains(t, string(generated), `w.metrics.Inflight(context.Background(), w.name, "testsamples", w.intr, "Method1", 1)`)
+	require.Contains(t, string(generated), `defer w.metrics.Inflight(context.Background(), w.name, "testsamples", w.intr, "Method1", -1)`)
+
+	// inflight is not included in all
+	tv = createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+	}), wd, "test.go", []string{"NoResult"})
+	require.NoError(t, tv.Walk())
+
+	generated, err = os.ReadFile(path.Join(wd, "test.misura.go"))
+	require.NoError(t, err)
+	require.NotContains(t, string(generated), "Inflight(")
+}
+
+func TestWrapperClassify(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports:  true,
+		Metrics:        []string{"all"},
+		ClassifyErrors: true,
+	}), wd, "test.go", []string{"NoParams"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "test.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), `duration time.Duration, class string, err error)`)
+	require.Contains(t, string(generated), `classifier misura.ErrorClassifier,`)
+	require.Contains(t, string(generated), `w.classifier.Classify(err), err)`)
+}
+
+func TestWrapperSample(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all", "inflight"},
+		RecoverPanics: true,
+		Sample:        true,
+	}), wd, "test.go", []string{"NoParams"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "test.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), `sampler misura.Sampler,`)
+	require.Regexp(t, `rate[0-9A-F]+, sampled[0-9A-F]+ := w\.sampler\.Sample\("Method1"\)`, string(generated))
+	require.Regexp(t, `ctx[0-9A-F]+ := misura\.WithSampleRate\(context\.Background\(\), rate[0-9A-F]+\)`, string(generated))
+	require.Contains(t, string(generated), `err = misura.NewPanicError(r)`)
+	// unsampled calls are still counted as in-flight
+	require.Regexp(t, `if !sampled[0-9A-F]+ \{\n\s+// in-flight calls are counted for every call to keep the gauge accurate\n\s+w\.metrics\.Inflight\(context\.Background\(\), w\.name, "testsamples", w\.intr, "Method1", 1\)\n\s+defer w\.metrics\.Inflight\(context\.Background\(\), w\.name, "testsamples", w\.intr, "Method1", -1\)`, string(generated))
+}
+
+func TestWrapperBench(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Bench:         true,
+	}), wd, "annotations.go", []string{"Variadic"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations_misura_bench_test.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "func BenchmarkVariadicList(b *testing.B) {")
+	require.Contains(t, string(generated), "func BenchmarkVariadicLen(b *testing.B) {")
+	require.Contains(t, string(generated), `NewVariadicPrometheusWrapperImpl("bench", noopVariadicBench{}, nil, nil)`)
+	require.Regexp(t, `\.impl\.List\(args[0-9A-F]+\.Ctx, args[0-9A-F]+\.Prefix, args[0-9A-F]+\.Ids\.\.\.\)`, string(generated))
+
+	wd = copyFilesHelper(t)
+	tv = createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"retry"},
+		Bench:         true,
+	}), wd, "annotations.go", []string{"Variadic"})
+	require.NoError(t, tv.Walk())
+	require.NoFileExists(t, path.Join(wd, "annotations_misura_bench_test.go"), "metrics wrapper is not generated")
+}
+
+func TestWrapperContract(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all", "panic", "inflight"},
+		RecoverPanics: true,
+		Contract:      true,
+	}), wd, "annotations.go", []string{"Variadic"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations_misura_contract_test.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "var _ Variadic = (*VariadicPrometheusWrapperImpl)(nil)")
+	require.Contains(t, string(generated), "func TestVariadicPrometheusWrapperImplList(t *testing.T) {")
+	require.Contains(t, string(generated), `recorder.check(t, "List", "Total", "Failure")`)
+	require.Contains(t, string(generated), `recorder.check(t, "Len", "Total", "Panic")`)
+	require.Contains(t, string(generated), "var panicErr *misura.PanicError")
+	require.Regexp(t, `args[0-9A-F]+\.Ids = make\(\[\]string, 1\)`, string(generated))
+}
+
+func TestWrapperConstructor(t *testing.T) {
+	for _, ctorError := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ctor_error_%t", ctorError), func(t *testing.T) {
+			wd := copyFilesHelper(t)
+			tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+				FormatImports:     true,
+				Metrics:           []string{"all"},
+				Wrappers:          []string{"metrics", "retry", "limit", "hooks"},
+				ConstructorErrors: ctorError,
+			}), wd, "test.go", []string{"NoParams"})
+			require.NoError(t, tv.Walk())
+
+			generated, err := os.ReadFile(path.Join(wd, "test.misura.go"))
+			require.NoError(t, err)
+			require.Contains(t, string(generated), "var _ NoParams = (*NoParamsPrometheusWrapperImpl)(nil)")
+			require.Contains(t, string(generated), "var _ NoParams = (*NoParamsRetryWrapperImpl)(nil)")
+			require.Contains(t, string(generated), "metrics = noopNoParamsMetrics{}")
+			require.Contains(t, string(generated), "metrics = noopNoParamsRetryMetrics{}")
+			require.Contains(t, string(generated), "metrics = noopNoParamsLimitMetrics{}")
+			require.Contains(t, string(generated), "limiters = &misura.LimiterGroup{}")
+			for _, wn := range []string{"PrometheusWrapperImpl", "RetryWrapperImpl", "LimitWrapperImpl", "HooksWrapperImpl"} {
+				if ctorError {
+					require.Contains(t, string(generated), ") (*NoParams"+wn+", error) {")
+					require.Contains(t, string(generated), `return nil, fmt.Errorf("NewNoParams`+wn+`: %w", misura.ErrNilWrapped)`)
+				} else {
+					require.Contains(t, string(generated), `panic("misura: NewNoParams`+wn+`: wrapped is nil")`)
+				}
+			}
+		})
+	}
+}
+
+func TestWrapperStandardLibrary(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitor(t, wd, "test.go", []string{"NoParams"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "test.misura.go"))
+	require.NoError(t, err)
+	// wrappers generated without flags only depend on the standard library
+	require.NotContains(t, string(generated), `"github.com/itzloop/misura/misura"`)
+	require.Contains(t, string(generated), "clock interface{ Now() time.Time },")
+	require.Contains(t, string(generated), "clock = systemNoParamsClock{}")
+	require.Regexp(t, `start\w+ := w.clock.Now\(\)`, string(generated))
+	require.Regexp(t, `duration\w+ := w.clock.Now\(\).Sub\(start\w+\)`, string(generated))
+}
+
+func TestWrapperOptions(t *testing.T) {
+	for _, ctorError := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ctor_error_%t", ctorError), func(t *testing.T) {
+			wd := copyFilesHelper(t)
+			tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+				FormatImports:     true,
+				Metrics:           []string{"all"},
+				Sample:            true,
+				ConstructorErrors: ctorError,
+				Options:           true,
+			}), wd, "test.go", []string{"NoParams"})
+			require.NoError(t, tv.Walk())
+
+			generated, err := os.ReadFile(path.Join(wd, "test.misura.go"))
+			require.NoError(t, err)
+			require.Contains(t, string(generated), `o := misura.NewOptions("NoParams", opts...)`)
+			require.Contains(t, string(generated), "sampler: o.Sampler,")
+			require.Contains(t, string(generated), "w.metrics, ok = o.Recorder.(interface {")
+			require.Contains(t, string(generated), "func WithNoParamsRecorder(metrics interface {")
+			require.Contains(t, string(generated), "}) misura.Option {")
+			require.Regexp(t, `labeled\w+ := misura.ContextWithLabels\(ctx\w+, w.labels\)`, string(generated))
+			require.Regexp(t, `start\w+ := w.clock.Now\(\)`, string(generated))
+			require.NotContains(t, string(generated), "time.Since(")
+			if ctorError {
+				require.Contains(t, string(generated), "func NewNoParamsPrometheusWrapperImpl(wrapped NoParams, opts ...misura.Option) (*NoParamsPrometheusWrapperImpl, error) {")
+				require.Contains(t, string(generated), `return nil, fmt.Errorf("NewNoParamsPrometheusWrapperImpl: recorder %T doesn't implement metrics", o.Recorder)`)
+			} else {
+				require.Contains(t, string(generated), "func NewNoParamsPrometheusWrapperImpl(wrapped NoParams, opts ...misura.Option) *NoParamsPrometheusWrapperImpl {")
+				require.Contains(t, string(generated), `panic(fmt.Sprintf("misura: NewNoParamsPrometheusWrapperImpl: recorder %T doesn't implement metrics", o.Recorder))`)
+			}
+		})
+	}
+}
+
+func TestWrapperErrorless(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		t.Run(fmt.Sprintf("skip_errorless_%t", skip), func(t *testing.T) {
+			wd := copyFilesHelper(t)
+			tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+				FormatImports: true,
+				Metrics:       []string{"all"},
+				SkipErrorless: skip,
+			}), wd, "test.go", []string{"NoResult"})
+			require.NoError(t, tv.Walk())
+
+			generated, err := os.ReadFile(path.Join(wd, "test.misura.go"))
+			require.NoError(t, err)
+			if skip {
+				require.NotContains(t, string(generated), `w.metrics.Success(`)
+				return
+			}
+
+			require.Contains(t, string(generated), `w.metrics.Success(context.Background(), w.name, "testsamples", w.intr, "Method1", duration`)
+		})
+	}
+}
+
+func TestWrapperOK(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitor(t, wd, "annotations.go", []string{"OKResults"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "if !b {")
+	require.Contains(t, string(generated), "if !found {")
+	require.Contains(t, string(generated), "if !a.OK {")
+	require.Contains(t, string(generated), "if !st.OK {")
+	// miss is not included in all
+	require.NotContains(t, string(generated), "Miss(")
+	require.Contains(t, string(generated), "misura.ErrNotOK)")
+
+	tv = createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all", "miss"},
+	}), wd, "annotations.go", []string{"OKResults"})
+	require.NoError(t, tv.Walk())
+
+	generated, err = os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "Miss(ctx context.Context, name, pkg, intr, method string, duration time.Duration)")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidOK"})
+	require.ErrorContains(t, tv.Walk(), "InvalidOK: Len: //misura:ok requires the last result to be a bool")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidOKField"})
+	require.ErrorContains(t, tv.Walk(), "InvalidOKField: Send: //misura:ok=.Ok: a has no field named 'Ok'")
+}
+
+func TestWrapperRetry(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"retry"},
+	}), wd, "annotations.go", []string{"Retries"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.NotContains(t, string(generated), "RetriesPrometheusWrapperImpl")
+	require.Contains(t, string(generated), "func NewRetriesRetryWrapperImpl(")
+	require.Regexp(t, `err = misura.Retry\(ctx, policy\w+, func\(\) error \{`, string(generated))
+	require.Regexp(t, `policy\w+\.MaxAttempts = 5`, string(generated))
+	require.Contains(t, string(generated), "return w.wrapped.Len()")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidRetry"})
+	require.ErrorContains(t, tv.Walk(), "InvalidRetry: Do: //misura:retry=-1: max attempts must be a positive integer")
+}
+
+func TestWrapperBreaker(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"metrics", "breaker"},
+	}), wd, "annotations.go", []string{"Retries"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "RetriesPrometheusWrapperImpl")
+	require.Contains(t, string(generated), "func NewRetriesBreakerWrapperImpl(")
+	require.Contains(t, string(generated), `w.breakers.Allow("Do", func(from, to misura.BreakerState) {`)
+	require.NotContains(t, string(generated), `w.breakers.Allow("Len"`)
+}
+
+func TestWrapperTimeout(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"timeout"},
+	}), wd, "annotations.go", []string{"Timeouts"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), `w.policy.Timeout("Get", 1500*time.Millisecond)`)
+	require.Contains(t, string(generated), `w.policy.Timeout("Notify", 0)`)
+	require.Regexp(t, `ctx, cancel\w+ := misura.WithTimeout\(ctx, w.policy.Clock, timeout\w+\)`, string(generated))
+	require.Contains(t, string(generated), "return w.wrapped.Len()")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidTimeout"})
+	require.ErrorContains(t, tv.Walk(), "InvalidTimeout: Len: //misura:timeout requires a context.Context parameter")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidTypeTimeout"})
+	require.ErrorContains(t, tv.Walk(), "InvalidTypeTimeout: Get: //misura:timeout=abc: timeout must be a positive duration")
+}
+
+func TestWrapperLimit(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"limit"},
+	}), wd, "annotations.go", []string{"Retries"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "func NewRetriesLimitWrapperImpl(")
+	require.Contains(t, string(generated), `w.limiters.Acquire(ctx, "Get")`)
+	require.Contains(t, string(generated), `w.limiters.Acquire(context.Background(), "Do")`)
+	require.Contains(t, string(generated), "return w.wrapped.Len()")
+}
+
+func TestWrapperInterceptor(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"interceptor"},
+		Capture:       true,
+	}), wd, "annotations.go", []string{"Retries"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), `interceptors.For("Get"),`)
+	require.Contains(t, string(generated), `err = w.intercept(ctx, "Get", 0, []misura.Field{`)
+	require.Contains(t, string(generated), `_ = w.intercept(context.Background(), "Len", 0, []misura.Field{}, func(context.Context) error {`)
+
+	// annotated timeouts are passed to interceptors
+	tv = createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"interceptor"},
+	}), wd, "annotations.go", []string{"Timeouts"})
+	require.NoError(t, tv.Walk())
+
+	generated, err = os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), `err = w.intercept(ctx, "Get", 1500*time.Millisecond, nil, func(ctx context.Context) error {`)
+	require.Contains(t, string(generated), "Timeout: timeout,")
+}
+
+func TestWrapperHooks(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"hooks"},
+	}), wd, "annotations.go", []string{"Variadic"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "type VariadicHooks struct {")
+	require.Regexp(t, `BeforeList +func\(ctx context.Context, prefix string, ids \[\]string\)`, string(generated))
+	require.Regexp(t, `AfterList +func\(ctx context.Context, prefix string, ids \[\]string, a \[\]Status, err error\)`, string(generated))
+	require.Contains(t, string(generated), "w.hooks.AfterList(ctx, prefix, ids, a, err)")
+	require.Contains(t, string(generated), "a, err = w.wrapped.List(ctx, prefix, ids...)")
+}
+
+func TestWrapperFake(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"fake"},
+	}), wd, "annotations.go", []string{"Variadic"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.NotContains(t, string(generated), "VariadicPrometheusWrapperImpl")
+	require.Contains(t, string(generated), "type VariadicFake struct {")
+	require.Regexp(t, `ListFunc +func\(ctx context.Context, prefix string, ids \.\.\.string\) \(\[\]Status, error\)`, string(generated))
+	require.Regexp(t, `Ids +\[\]string`, string(generated))
+	require.Regexp(t, `func \(fake\w+ \*VariadicFake\) ListCalls\(\) \[\]VariadicFakeListCall \{`, string(generated))
+	require.Regexp(t, `return fake\w+\.ListFunc\(ctx, prefix, ids\.\.\.\)`, string(generated))
+}
+
+func TestWrapperFakeCollision(t *testing.T) {
+	wd := copyFilesHelper(t)
+	opts := GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"fake"},
+	}
+
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, opts), wd, "annotations.go", []string{"Resettable"})
+	require.ErrorContains(t, tv.Walk(), "Resettable: Reset collides with a field or method of ResettableFake")
+
+	tv = createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, opts), wd, "annotations.go", []string{"CallsCollision"})
+	require.ErrorContains(t, tv.Walk(), "CallsCollision: GetCalls collides with a field or method of CallsCollisionFake")
+
+	// Reset is only generated for fakes
+	opts.Wrappers = []string{"metrics"}
+	tv = createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, opts), wd, "annotations.go", []string{"Resettable"})
+	require.NoError(t, tv.Walk())
+}
+
+func TestWrapperRecord(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"record"},
+	}), wd, "annotations.go", []string{"Variadic"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "type VariadicRecordListParams struct {")
+	require.NotContains(t, string(generated), "Ctx context.Context", "ctx is not recorded")
+	require.Contains(t, string(generated), `w.recorder.Record("Variadic", "List", VariadicRecordListParams{`)
+	require.Contains(t, string(generated), "func NewVariadicReplay(replayer *misura.CallReplayer) *VariadicReplay {")
+	require.Contains(t, string(generated), `w.replayer.Replay("Variadic", "Len", VariadicRecordLenParams{}`)
+}
+
+func TestWrapperFault(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"fault"},
+	}), wd, "annotations.go", []string{"Variadic"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "func NewVariadicFaultWrapperImpl(")
+	require.Regexp(t, `w\.injector\.Pick\("Variadic", "List"\)`, string(generated))
+	require.Regexp(t, `if err = fault[0-9A-F]+\.Apply\(ctx, w\.injector\.Clock\); err != nil`, string(generated))
+	require.Regexp(t, `_ = fault[0-9A-F]+\.Apply\(context\.Background\(\), w\.injector\.Clock\)`, string(generated))
+}
+
+func TestWrapperCache(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"cache"},
+	}), wd, "annotations.go", []string{"Cached"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(generated), "type CachedCacheGetKey struct {")
+	require.Contains(t, string(generated), "misura.NewCache[CachedCacheGetKey, CachedCacheGetValue](policy, 30*time.Second)")
+	require.Contains(t, string(generated), "misura.NewCache[string, CachedCacheTagsValue](policy, 0)")
+	require.Contains(t, string(generated), "w.cacheTags.Get(tagsKey(ids), func()")
+	require.Contains(t, string(generated), "return w.wrapped.Len()")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidCache"})
+	require.ErrorContains(t, tv.Walk(), "InvalidCache: Tags: //misura:cache requires //misura:cachekey since ids is not comparable")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidCacheStruct"})
+	require.ErrorContains(t, tv.Walk(), "InvalidCacheStruct: Find: //misura:cache requires //misura:cachekey since q is not comparable")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidCacheInterface"})
+	require.ErrorContains(t, tv.Walk(), "InvalidCacheInterface: Find: //misura:cache requires //misura:cachekey since q is not comparable")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidCacheImported"})
+	require.ErrorContains(t, tv.Walk(), "InvalidCacheImported: Find: //misura:cache requires //misura:cachekey since q is not comparable")
+}
+
+func TestWrapperHedge(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, GeneratorOpts{
+		FormatImports: true,
+		Metrics:       []string{"all"},
+		Wrappers:      []string{"hedge"},
+	}), wd, "annotations.go", []string{"Hedged"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Regexp(t, `misura\.Hedge\(ctx, w\.hedger, "Get", 50\*time\.Millisecond, func\(ctx context\.Context\)`, string(generated))
+	require.Regexp(t, `misura\.Hedge\(ctx, w\.hedger, "Notify", 0, func\(ctx context\.Context\)`, string(generated))
+	require.Contains(t, string(generated), "return w.wrapped.Len()")
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidHedge"})
+	require.ErrorContains(t, tv.Walk(), "InvalidHedge: Len: //misura:hedge requires a context.Context parameter")
+}
+
+func TestWrapperLabels(t *testing.T) {
+	wd := copyFilesHelper(t)
+	tv := createTypeVisitor(t, wd, "annotations.go", []string{"Labeled"})
+	require.NoError(t, tv.Walk())
+
+	generated, err := os.ReadFile(path.Join(wd, "annotations.misura.go"))
+	require.NoError(t, err)
+	require.Regexp(t, `labels\w+ := misura.CloneLabels\(nil, 3\)`, string(generated))
+	require.Regexp(t, `if req != nil {\s+labels\w+\["region"\] = req.Region`, string(generated))
+	require.Regexp(t, `if req != nil {\s+labels\w+\["shard"\] = fmt.Sprint\(req.Shard\)`, string(generated))
+	require.Regexp(t, `if req != nil && req.Meta != nil {\s+labels\w+\["tenant"\] = req.Meta.Tenant`, string(generated))
+	require.Regexp(t, `labels\w+\["kind"\] = kind`, string(generated))
+	require.Regexp(t, `labeled\w+ := misura.ContextWithLabels\(ctx, labels\w+\)`, string(generated))
+
+	tv = createTypeVisitor(t, wd, "annotations.go", []string{"InvalidLabel"})
+	require.ErrorContains(t, tv.Walk(), "InvalidLabel: Get: //misura:label=zone:req.Zone: req has no field named 'Zone'")
+}
+
+// TestWrapperCompiles generates wrappers for annotations.go using different
+// flags into a temporary module and runs go vet on it, so generated code
+// and tests are type checked, not only parsed.
+func TestWrapperCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go vet of generated code in short mode")
+	}
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not in PATH")
+	}
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	root := filepath.Dir(wd)
+
+	mod := t.TempDir()
+	goMod := fmt.Sprintf("module compilecheck\n\ngo 1.21\n\nrequire github.com/itzloop/misura v0.0.0\n\nreplace github.com/itzloop/misura => %s\n", root)
+	require.NoError(t, os.WriteFile(path.Join(mod, "go.mod"), []byte(goMod), 0644))
+	goSum, err := os.ReadFile(path.Join(root, "go.sum"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path.Join(mod, "go.sum"), goSum, 0644))
+
+	targets := []string{"OKResults", "Retries", "Timeouts", "Variadic", "Cached", "Hedged", "Labeled", "Shadowed"}
+	wrappers := []string{"metrics", "retry", "breaker", "timeout", "limit", "interceptor", "hooks", "fake", "record", "fault", "cache", "hedge"}
+	cases := map[string]GeneratorOpts{
+		"default": {Metrics: []string{"all"}},
+		"all": {
+			Metrics:        []string{"all", "panic", "inflight", "miss"},
+			Wrappers:       wrappers,
+			RecoverPanics:  true,
+			ClassifyErrors: true,
+			Sample:         true,
+			Capture:        true,
+			Bench:          true,
+			Contract:       true,
+		},
+		"options": {
+			Metrics:           []string{"duration", "total", "miss"},
+			Wrappers:          wrappers,
+			ConstructorErrors: true,
+			Options:           true,
+			Bench:             true,
+			Contract:          true,
+		},
+		"skip_errorless": {
+			Metrics:       []string{"error", "success"},
+			SkipErrorless: true,
+			Contract:      true,
+		},
+	}
+
+	src, err := os.ReadFile(path.Join(wd, "test_samples", "annotations.go"))
+	require.NoError(t, err)
+
+	for name, opts := range cases {
+		dir := path.Join(mod, name)
+		require.NoError(t, os.Mkdir(dir, 0755))
+		require.NoError(t, os.WriteFile(path.Join(dir, "annotations.go"), src, 0644))
+
+		opts.FormatImports = true
+		tv := createTypeVisitorWithGenerator(t, createGeneratorWithOpts(t, opts), dir, "annotations.go", targets)
+		require.NoError(t, tv.Walk(), name)
+	}
+
+	cmd := exec.Command(goBin, "vet", "./...")
+	cmd.Dir = mod
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
 func createTypeVisitor(t *testing.T, cwd, filename string, targets []string) *TypeVisitor {
 	t.Helper()
 
-	tv, err := NewTypeVisitor(createGenerator(t), TypeVisitorOpts{
+	return createTypeVisitorWithGenerator(t, createGenerator(t), cwd, filename, targets)
+}
+
+func createTypeVisitorWithGenerator(t *testing.T, g *WrapperGenerator, cwd, filename string, targets []string) *TypeVisitor {
+	t.Helper()
+
+	tv, err := NewTypeVisitor(g, TypeVisitorOpts{
 		FilePath: path.Join(cwd, filename),
 		Targets:  targets,
 	})
@@ -82,15 +713,21 @@ func createTypeVisitor(t *testing.T, cwd, filename string, targets []string) *Ty
 
 func createGenerator(t *testing.T) *WrapperGenerator {
 	t.Helper()
-	tmpl, err := template.ParseGlob(path.Join("..", "templates", "*.gotmpl"))
-	require.NoError(t, err)
-	require.NotNil(t, tmpl)
 
-	g, err := NewWrapperGenerator(GeneratorOpts{
+	return createGeneratorWithOpts(t, GeneratorOpts{
 		FormatImports: true,
-		Template:      tmpl,
 		Metrics:       []string{"all"},
 	})
+}
+
+func createGeneratorWithOpts(t *testing.T, opts GeneratorOpts) *WrapperGenerator {
+	t.Helper()
+	tmpl, err := template.ParseGlob(path.Join("..", "templates", "*.gotmpl"))
+	require.NoError(t, err)
+	require.NotNil(t, tmpl)
+
+	opts.Template = tmpl
+	g, err := NewWrapperGenerator(opts)
 
 	require.NoError(t, err)
 	require.NotNil(t, g)